@@ -29,18 +29,27 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fileutils"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"licensedb"
 	"log"
 	"notice"
+	"noticecache"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"strutils"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"tagger"
+	"time"
 	"version"
 )
 
@@ -52,6 +61,7 @@ type FileInfo struct {
 type NoticeMsg struct {
 	path   string
 	notice *notice.Notice
+	err    *notice.Error // the failure to make the notice, if any
 }
 
 const LicenseDBNumBuckets = 1000000
@@ -62,7 +72,16 @@ var ignoreErrors bool
 var showLic bool
 var quiet bool
 var copyrightTagger *tagger.Tagger
+var granularity notice.Granularity
+var cache *noticecache.Cache
+var checkpoint *noticecache.Checkpoint
+var ctx context.Context // cancelled when the scan is cut short
+var numQueued uint64    // files sent to the workers, for the progress
+var numDone uint64      // files the workers are done with
+var numTotal int64 = -1 // files to scan, -1 until they are all queued
+var numSkipped uint64   // files and paths left for a resumed scan when it is cut short
 var wg sync.WaitGroup
+var walkChan chan FileInfo // the files found, workerChan or queued for it
 var workerChan chan FileInfo
 var noticeChan chan NoticeMsg
 var doneChan chan bool
@@ -134,28 +153,94 @@ func ExtraUsage() {
   via a file or stdin.  This makes it easy to build complex query
   pipelines with tools such as find(1).  See the '-i' and '-0'
   command line options for details.
+
+  Each file a notice is in is listed as "path:line", the line
+  being the first of the notice in the file, followed by the
+  lines and byte offsets of the parts of the file the notice was
+  taken from.  -showlic and -verbose give files as "path:line"
+  too.
+
+  With -srcdir, a page of the source of each file with a notice
+  is saved in the given directory, HTML escaped, with line numbers
+  and the parts the notice was taken from highlighted, and each
+  "path:line" links to it at that line.  The links are relative
+  to the -o document, so the two can be moved together.  The page
+  of a file is at its path, with ".html" added; those of absolute
+  paths are under "_root", and each ".." is made "_up".
+
+  Files with no copyright notice and files of types the tool
+  can't read (binaries, archives, images) are not listed with the
+  notices, only counted at the end of the -o document.  With
+  -triage, they are listed in a separate HTML report: a table of
+  how many there are in each directory, each directory's files
+  with their status or file type, and the unsupported files by
+  type, so that what needs a look by hand is easy to find.
+
+  A file that can't be read, whose type file(1) can't tell, that
+  the tagger matches a copyright notice in but then can't find it
+  in, or that makes the tagger panic aborts the run, unless
+  -continue is given: then it is logged (unless -quiet) and the
+  run carries on, and the files that failed are listed at the end
+  of the -o document by the kind of error, with what went wrong.
+
+  A scan can be cut short with Ctrl-C (SIGINT), SIGTERM or after
+  -timeout: the files being worked on are finished, the rest are
+  skipped, and the reports are written with what was done, marked
+  incomplete at the top, and the tool exits with status 1.  A second
+  Ctrl-C aborts at once.  With -checkpoint, the files done are saved
+  to the given file, and a scan run again with the same -checkpoint
+  (and the same tagger settings) doesn't read the ones that haven't
+  changed since, but takes their notices from it; the checkpoint is
+  removed once a scan runs to the end.
+
+  While it scans, the files done, those queued for the workers, how
+  many are done a second and, once all the files have been found,
+  when all will be done, are shown on stderr when it is a terminal
+  (-progress).  At the end, -stats writes a summary of the run: the
+  files by type and status, the licenses and errors, how many of
+  the notices were duplicates, the cache hits, the histogram of the
+  notices compared in the buckets of the notice database, the time
+  spent telling file types, finding comments and tagging, and the
+  slowest files, as text or, with "-statsformat json", as JSON.
+
+  Files with identical contents are only examined once.  The -cache
+  option keeps what was learned about them between runs; the cache
+  is discarded when the tool version or the tagger model changes.
 `)
 }
 
-func handleParseError(path string, err error) error {
-	if ignoreErrors {
-		if !quiet {
-			log.Printf("[ERROR] %s: %s\n", path, err)
-		}
-		return nil
+//
+// Aborts on the failure to make the notice of path, or with -continue
+// logs it and records it for the errors section of the report
+//
+func handleParseError(path string, err error) {
+	e := notice.NewError(path, notice.ReadError, err)
+	if !ignoreErrors {
+		log.Fatal(e)
+	}
+	if !quiet {
+		log.Printf("[ERROR] %s\n", e)
 	}
 
-	return err
+	noticeChan <- NoticeMsg{path: path, err: e}
 }
 
 // Starts N workers determined by GOMAXPROCS
 // And start a secretary worker
-func setupWorkers() {
+// With queue the files found are queued for the workers, so that the walk
+// runs ahead of them and all the files are counted early on
+func setupWorkers(queue bool) {
 	var numWorkers int = runtime.GOMAXPROCS(-1)
 	workerChan = make(chan FileInfo, numWorkers)
 	noticeChan = make(chan NoticeMsg, numWorkers)
 	doneChan = make(chan bool)
 
+	walkChan = workerChan
+	if queue {
+		walkChan = make(chan FileInfo, numWorkers)
+		go queueWork(walkChan, workerChan)
+	}
+
 	// start up the one secretary that will record the notices
 	go noticeHandler(noticeChan, doneChan)
 
@@ -165,6 +250,33 @@ func setupWorkers() {
 	}
 }
 
+// Passes the files found on to the workers as they take them, keeping the
+// rest in the meantime, and closes the worker channel once the walk is
+// done and they have all been passed on
+func queueWork(walkChan chan FileInfo, workerChan chan FileInfo) {
+	var queue []FileInfo
+	for walkChan != nil || len(queue) > 0 {
+		var sendChan chan FileInfo // nil, which blocks, while there is nothing to send
+		var next FileInfo
+		if len(queue) > 0 {
+			sendChan = workerChan
+			next = queue[0]
+		}
+		select {
+		case fileInfo, ok := <-walkChan:
+			if !ok {
+				walkChan = nil
+				continue
+			}
+			queue = append(queue, fileInfo)
+		case sendChan <- next:
+			queue[0] = FileInfo{}
+			queue = queue[1:]
+		}
+	}
+	close(workerChan)
+}
+
 // The worker that keeps trying to pull work from the worker channel then attempts
 // to parse that info to create a notice then if a notice was created send that onto a
 // channel to be logged in ldb
@@ -174,11 +286,25 @@ func fileHandler(workerChan chan FileInfo, noticeChan chan NoticeMsg, doneChan c
 		if !ok {                     // ok is set to false when workerChan is closed
 			break
 		}
+		if ctx.Err() != nil { // cancelled, leave the rest for a resumed scan
+			atomic.AddUint64(&numSkipped, 1)
+			continue
+		}
 
-		notice := FileParse(fileInfo.path, fileInfo.info)
+		notice, raw, err := FileParse(fileInfo.path, fileInfo.info)
+		if fileInfo.info.Mode().IsRegular() {
+			atomic.AddUint64(&numDone, 1)
+		}
 		if notice != nil {
+			serr := ldb.SaveSource(fileInfo.path, notice, raw, verbose)
+			if serr != nil {
+				log.Fatal(serr)
+			}
 			noticeChan <- NoticeMsg{path: fileInfo.path, notice: notice}
 		}
+		if err != nil {
+			handleParseError(fileInfo.path, err)
+		}
 	}
 	doneChan <- true
 }
@@ -192,7 +318,12 @@ func noticeHandler(noticeChan chan NoticeMsg, doneChan chan bool) {
 			break
 		}
 
-		ldb.Add(noticeMsg.path, noticeMsg.notice, verbose)
+		if noticeMsg.err != nil {
+			ldb.AddError(noticeMsg.err)
+		}
+		if noticeMsg.notice != nil {
+			ldb.Add(noticeMsg.path, noticeMsg.notice, verbose)
+		}
 	}
 	doneChan <- true
 }
@@ -200,7 +331,7 @@ func noticeHandler(noticeChan chan NoticeMsg, doneChan chan bool) {
 // Gathers all the workers ensuring all work is done and all go routines have stopped before allowing
 // the program to continue
 func shutdownWorkers() {
-	close(workerChan) //done sending work
+	close(walkChan) //done sending work
 	var numWorkers int = runtime.GOMAXPROCS(-1)
 	for i := 0; i < numWorkers; i++ {
 		<-doneChan
@@ -213,11 +344,19 @@ func shutdownWorkers() {
 }
 
 func sendWork(path string, info os.FileInfo, err error) error {
+	if ctx.Err() != nil {
+		atomic.AddUint64(&numSkipped, 1)
+		return filepath.SkipAll
+	}
 	if err != nil {
-		return handleParseError(path, err)
+		handleParseError(path, err)
+		return nil
 	}
 
-	workerChan <- FileInfo{path: path, info: info}
+	if info.Mode().IsRegular() {
+		atomic.AddUint64(&numQueued, 1)
+	}
+	walkChan <- FileInfo{path: path, info: info}
 
 	return nil
 }
@@ -227,25 +366,79 @@ func sendWork(path string, info os.FileInfo, err error) error {
 // Files:	Read and process
 // Others:	Skip
 //
-func FileParse(path string, info os.FileInfo) *notice.Notice {
+// A file the tagger fails on can have both a notice and an error.  The
+// text of the file is returned too, if it was read, for its source page.
+//
+func FileParse(path string, info os.FileInfo) (*notice.Notice, []byte, error) {
 	if !info.Mode().IsRegular() {
 		if verbose {
 			log.Printf("[INFO] Skipping %s (not a file)\n", path)
 		}
-		return nil
+		return nil, nil, nil
+	}
+
+	if checkpoint == nil {
+		return cachedNotice(path)
 	}
 
-	lic, err := notice.NewNoticeFromFile(path, verbose, showLic, copyrightTagger)
+	var raw []byte
+	lic := checkpoint.Get(path, info)
+	if lic != nil {
+		if verbose {
+			log.Printf("[CHECKPOINT] %s: done\n", path)
+		}
+	} else {
+		var err error
+		lic, raw, err = cachedNotice(path)
+		if err != nil {
+			return lic, raw, err
+		}
+	}
+	checkpoint.Put(path, info, lic)
+
+	return lic, raw, nil
+}
+
+//
+// Files with identical contents produce identical notices, so look the
+// contents up in the notice cache before telling their type with
+// file(1) and handing them to the tagger.  The contents are returned
+// with the notice of a file that is tagged.
+//
+func cachedNotice(path string) (*notice.Notice, []byte, error) {
+	raw, err := ioutil.ReadFile(path)
 	if err != nil {
-		if ignoreErrors {
-			if !quiet {
-				log.Printf("[ERROR] %s: %s\n", path, err)
-			}
+		return nil, nil, notice.NewError(path, notice.ReadError, err)
+	}
+
+	key := noticecache.Sum(raw)
+	lic := cache.Get(key)
+	if lic != nil {
+		if verbose {
+			log.Printf("[CACHE] %s: hit\n", path)
 		}
-		log.Fatal(err)
+		if lic.Status == notice.Unsupported {
+			return lic, nil, nil
+		}
+		return lic, raw, nil
+	}
+
+	lic, magic, err := notice.CheckFile(path, verbose, showLic)
+	if err != nil {
+		return lic, nil, err
+	}
+	if lic != nil {
+		cache.Put(key, lic)
+		return lic, nil, nil
+	}
+
+	lic, err = notice.NewNoticeFromBytes(path, raw, magic, verbose, showLic, copyrightTagger, granularity)
+	if err != nil {
+		return lic, raw, err
 	}
+	cache.Put(key, lic)
 
-	return lic
+	return lic, raw, nil
 }
 
 // walks all files sending the path to sendWork
@@ -255,6 +448,122 @@ func ProcessFile(path string) error {
 	return err
 }
 
+//
+// Returns how the HTML document written to outPath links to target, the
+// source pages directory or the triage report: relative to it, so that
+// the two can be moved together, or as given if the document goes to
+// stdout
+//
+func relativeLink(target string, outPath string) string {
+	if outPath == "" {
+		return filepath.ToSlash(target)
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return filepath.ToSlash(target)
+	}
+	absOut, err := filepath.Abs(outPath)
+	if err != nil {
+		return filepath.ToSlash(absTarget)
+	}
+	rel, err := filepath.Rel(filepath.Dir(absOut), absTarget)
+	if err != nil {
+		return filepath.ToSlash(absTarget)
+	}
+	return filepath.ToSlash(rel)
+}
+
+//
+// Writes the progress of the scan to stderr until told to stop on done:
+// every second over the last line on a terminal, else logged every ten
+//
+func showProgress(done chan bool, terminal bool) {
+	interval := 10 * time.Second
+	if terminal {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-done:
+			if terminal {
+				fmt.Fprintf(os.Stderr, "\r%s\x1b[K\n", progressLine(start))
+			}
+			done <- true
+			return
+		case <-ticker.C:
+			if terminal {
+				fmt.Fprintf(os.Stderr, "\r%s\x1b[K", progressLine(start))
+			} else {
+				log.Printf("[PROGRESS] %s\n", progressLine(start))
+			}
+		}
+	}
+}
+
+// Returns how many files are done and queued, how fast, and when all will
+// be done once they have all been queued
+func progressLine(start time.Time) string {
+	done := atomic.LoadUint64(&numDone)
+	queued := atomic.LoadUint64(&numQueued) - done
+	rate := float64(done) / time.Since(start).Seconds()
+
+	total := atomic.LoadInt64(&numTotal)
+	if total < 0 {
+		return fmt.Sprintf("%d files done, %d queued, %.1f files/s", done, queued, rate)
+	}
+	eta := "?"
+	if rate > 0 && int64(done) <= total {
+		eta = time.Duration(float64(total-int64(done)) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%d/%d files done, %d queued, %.1f files/s, ETA %s", done, total, queued, rate, eta)
+}
+
+// Writes the statistics of the run to path, or stderr if it is "-", in
+// format: text or json
+func writeStats(path string, format string) error {
+	stats := ldb.Stats()
+	stats.CacheHits = atomic.LoadUint64(&cache.Hits)
+	stats.CacheMisses = atomic.LoadUint64(&cache.Misses)
+
+	outfile := os.Stderr
+	if path != "-" {
+		var err error
+		outfile, err = os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer outfile.Close()
+	}
+
+	if format == "json" {
+		out, err := json.MarshalIndent(stats, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = outfile.Write(append(out, '\n'))
+		return err
+	}
+	return stats.WriteText(outfile)
+}
+
+//
+// Cancels the scan on SIGINT or SIGTERM, once: a second one kills the
+// tool as usual
+//
+func cancelOnSignal(cancel context.CancelCauseFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+	signal.Stop(signals)
+
+	log.Printf("[CANCEL] %s: finishing the files being worked on, again to abort\n", sig)
+	cancel(fmt.Errorf("was stopped by a signal (%s)", sig))
+}
+
 //
 // Workaround an OSX issue "regexec error 17, (illegal byte sequence)"
 //
@@ -274,11 +583,44 @@ func fixenv() {
 	}
 }
 
+func saveModel(path string) error {
+	outfile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = copyrightTagger.SaveModel(outfile)
+	if err != nil {
+		outfile.Close()
+		return err
+	}
+
+	return outfile.Close()
+}
+
 func main() {
 	var inPath string
 	var stylePath string
 	var licenseDir string
+	var sourceDir string
+	var triagePath string
 	var corpusPath string
+	var corpusFormat string
+	var modelPath string
+	var modelKind string
+	var lexiconPath string
+	var prefilterPath string
+	var grammarPath string
+	var saveModelPath string
+	var cachePath string
+	var checkpointPath string
+	var timeout time.Duration
+	var progressMode string
+	var statsPath string
+	var statsFormat string
+	var threshold float64
+	var sortBy string
+	var granularityName string
 	var showVer bool
 	var outPath string
 	var zeroDelim bool
@@ -288,12 +630,30 @@ func main() {
 	flag.StringVar(&inPath, "i", "", "File to read list of files and directories from (use '-' for stdin)")
 	flag.StringVar(&outPath, "o", "", "File to write HTML formatted licensedb to (default = stdout)")
 	flag.StringVar(&licenseDir, "ldir", "", "Directory to save licenses to (default = don't save) ")
+	flag.StringVar(&triagePath, "triage", "", "File to write an HTML report of the files with no copyright notice or of unsupported types to (default = don't write)")
+	flag.StringVar(&sourceDir, "srcdir", "", "Directory to save a page of the source of each file with a notice to, highlighting the notice (default = don't save)")
 
 	flag.StringVar(&stylePath, "style", "", "Use this css stylesheet (default = embed)")
-	flag.StringVar(&corpusPath, "corpus", "", "The path the the corpus to use for training the tagger model")
+	flag.StringVar(&corpusPath, "corpus", "", "Train the tagger model from this corpus (default = use the built in model)")
+	flag.StringVar(&corpusFormat, "corpusformat", "auto", "Format of the -corpus: native, brown, conll, ptb or auto")
+	flag.StringVar(&modelPath, "model", "", "Load the tagger model from this file (default = use the built in model)")
+	flag.StringVar(&modelKind, "tagger", tagger.DefaultKind, "Kind of tagger model to use with -corpus or the built in corpus: "+strings.Join(tagger.Kinds(), ", "))
+	flag.StringVar(&lexiconPath, "lexicon", "", "Add the company suffixes, organizations and abbreviations in this file to the built in lexicon")
+	flag.StringVar(&grammarPath, "grammar", "", "Find copyright notices by the grammar in this file (default = the built in grammar)")
+	flag.StringVar(&prefilterPath, "prefilter", "", "Only tag the text near the patterns in this file for copyright notices (default = the built in patterns, 'none' = tag all text)")
+	flag.StringVar(&saveModelPath, "savemodel", "", "Save the tagger model to this file and exit")
+	flag.StringVar(&cachePath, "cache", "", "File to keep the notice cache in between runs (default = don't keep)")
+	flag.StringVar(&checkpointPath, "checkpoint", "", "File to save the files done to when the scan is cut short, and to resume it from (default = don't save)")
+	flag.StringVar(&progressMode, "progress", "auto", "Show the progress of the scan on stderr: auto (when it is a terminal, without -quiet or -verbose), always or never")
+	flag.StringVar(&statsPath, "stats", "", "File to write the statistics of the run to, '-' for stderr (default = don't write)")
+	flag.StringVar(&statsFormat, "statsformat", "text", "Format of the -stats: text or json")
+	flag.DurationVar(&timeout, "timeout", 0, "Cut the scan short after this long, e.g. 2h, and report the files done (default = no limit)")
+	flag.Float64Var(&threshold, "threshold", 0, "Only report copyright notices with at least this confidence, from 0 to 1 (default = report all)")
+	flag.StringVar(&sortBy, "sort", "path", "Sort the notices by: path or confidence (the highest first)")
+	flag.StringVar(&granularityName, "granularity", "statement", "How much of a comment with a copyright notice to report: statement (the copyright statements), paragraph (their paragraphs and the license paragraph after) or comment (the whole comment)")
 
 	flag.BoolVar(&zeroDelim, "0", false, "Pathnames read from the input file (-i) are \\0 delimited (default is \\n delimited)")
-	flag.BoolVar(&ignoreErrors, "continue", false, "Continue processing, ignoring errors (default is abort on error)")
+	flag.BoolVar(&ignoreErrors, "continue", false, "Continue processing after errors, listing the files that failed in the report (default is abort on error)")
 	flag.BoolVar(&quiet, "quiet", false, "Don't output errors (use in conjunction with '-continue')")
 	flag.BoolVar(&verbose, "verbose", false, "Turn on verbose debug output (default is off)")
 	flag.BoolVar(&showLic, "showlic", false, "show licenses found during processing")
@@ -310,11 +670,84 @@ func main() {
 	var err error
 
 	// Initialize the Tagger Model
-	if corpusPath == "" {
-		log.Fatal("corpus required")
+	switch {
+	case corpusPath != "" && modelPath != "":
+		log.Fatal("-corpus and -model are mutually exclusive")
+	case corpusPath != "":
+		var format tagger.CorpusFormat
+		format, err = tagger.ParseCorpusFormat(corpusFormat)
+		if err == nil {
+			copyrightTagger, err = tagger.NewFromCorpus(corpusPath, format, tagger.NewTrainOptions(modelKind))
+		}
+	case modelPath != "":
+		copyrightTagger, err = tagger.NewFromModel(modelPath)
+	default:
+		copyrightTagger, err = tagger.NewDefault(modelKind)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if lexiconPath != "" {
+		err = copyrightTagger.Lexicon.ReadFile(lexiconPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if grammarPath != "" {
+		grammar, err := tagger.ReadGrammarFile(grammarPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		copyrightTagger.SetGrammar(grammar)
+	}
+
+	switch prefilterPath {
+	case "":
+	case "none":
+		copyrightTagger.Prefilter = nil
+	default:
+		copyrightTagger.Prefilter, err = tagger.ReadPrefilterFile(prefilterPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if threshold < 0 || threshold > 1 {
+		log.Fatalf("-threshold %g is not from 0 to 1", threshold)
+	}
+	copyrightTagger.Threshold = threshold
+	if sortBy != "path" && sortBy != "confidence" {
+		log.Fatalf("-sort %q is not path or confidence", sortBy)
+	}
+	granularity, err = notice.ParseGranularity(granularityName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if statsFormat != "text" && statsFormat != "json" {
+		log.Fatalf("-statsformat %q is not text or json", statsFormat)
+	}
+	var progress, terminal bool
+	info, err := os.Stderr.Stat()
+	terminal = err == nil && info.Mode()&os.ModeCharDevice != 0
+	switch progressMode {
+	case "auto":
+		progress = terminal && !quiet && !verbose
+	case "always":
+		progress = true
+	case "never":
+	default:
+		log.Fatalf("-progress %q is not auto, always or never", progressMode)
+	}
+
+	if saveModelPath != "" {
+		err = saveModel(saveModelPath)
+		if err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
-	copyrightTagger = tagger.New(corpusPath)
 
 	for _, path := range flag.Args() {
 		err = fileutils.PathCheck(path, verbose)
@@ -324,9 +757,54 @@ func main() {
 	}
 
 	ldb = licensedb.NewLicenseDB(licenseDir, LicenseDBNumBuckets, 0)
+	if sourceDir != "" {
+		ldb.SourceDir = sourceDir
+		ldb.SourceLink = relativeLink(sourceDir, outPath)
+	}
+	if triagePath != "" {
+		ldb.TriageLink = relativeLink(triagePath, outPath)
+	}
+	ldb.SortByConfidence = sortBy == "confidence"
+
+	cacheVersion := strings.Join([]string{version.Version, copyrightTagger.NoticeSignature(), granularity.String()}, "/")
+	if cachePath == "" {
+		cache = noticecache.New(cacheVersion)
+	} else {
+		cache, err = noticecache.Load(cachePath, cacheVersion, verbose)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if checkpointPath != "" {
+		checkpoint, err = noticecache.LoadCheckpoint(checkpointPath, cacheVersion, verbose)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	setupWorkers()
+	var cancel context.CancelCauseFunc
+	ctx, cancel = context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("timed out after %s", timeout))
+		defer cancelTimeout()
+	}
+	go cancelOnSignal(cancel)
+
+	var progressDone chan bool
+	if progress {
+		progressDone = make(chan bool)
+		go showProgress(progressDone, terminal)
+	}
+
+	setupWorkers(progress)
 	for _, path := range flag.Args() {
+		if ctx.Err() != nil {
+			atomic.AddUint64(&numSkipped, 1)
+			break
+		}
 		err = ProcessFile(path)
 		if err != nil {
 			log.Fatal(err)
@@ -352,6 +830,10 @@ func main() {
 		}
 
 		for scanner.Scan() {
+			if ctx.Err() != nil {
+				atomic.AddUint64(&numSkipped, 1)
+				break
+			}
 			err = ProcessFile(scanner.Text())
 			if err != nil {
 				log.Fatal(err)
@@ -364,20 +846,104 @@ func main() {
 		}
 		infile.Close()
 	}
+	// all the files are queued, so the progress has its total
+	if ctx.Err() == nil {
+		atomic.StoreInt64(&numTotal, int64(atomic.LoadUint64(&numQueued)))
+	}
 	shutdownWorkers()
+	if progress {
+		progressDone <- true
+		<-progressDone
+	}
 
-	outfile := os.Stdout
-	if outPath != "" {
-		outfile, err = os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	// cut short only if a file was left out, not if the scan was done
+	// before it was cancelled
+	if atomic.LoadUint64(&numSkipped) > 0 {
+		ldb.Incomplete = context.Cause(ctx).Error()
+		log.Printf("[CANCEL] the scan %s, writing a partial report\n", ldb.Incomplete)
+	}
+	if checkpoint != nil {
+		err = saveCheckpoint(checkpointPath, ldb.Incomplete != "")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(ldb.Errors) > 0 && !quiet {
+		log.Printf("[ERROR] %d files failed, see the errors in the report\n", len(ldb.Errors))
+	}
+
+	if verbose {
+		log.Printf("[CACHE] %d hits, %d misses\n", cache.Hits, cache.Misses)
+	}
+	if cachePath != "" {
+		err = cache.Save(cachePath)
 		if err != nil {
 			log.Fatal(err)
 		}
+	}
+
+	err = writeDocument(outPath, stylePath, ldb.SortedSave)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if triagePath != "" {
+		err = writeDocument(triagePath, stylePath, ldb.SaveTriage)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if statsPath != "" {
+		err = writeStats(statsPath, statsFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if ldb.Incomplete != "" {
+		os.Exit(1)
+	}
+}
+
+//
+// Saves the checkpoint of a scan cut short to resume it from, or removes
+// that of a scan that ran to the end
+//
+func saveCheckpoint(path string, incomplete bool) error {
+	if !incomplete {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	total, done := checkpoint.Len()
+	log.Printf("[CHECKPOINT] %s: %d files done, %d by this scan, run again with -checkpoint to resume\n",
+		path, total, done)
+	return checkpoint.Save(path)
+}
+
+//
+// Writes an HTML document to path, or stdout if it is "", with the body
+// written by save
+//
+func writeDocument(path string, stylePath string, save func(outb *bufio.Writer, verbose bool) error) error {
+	outfile := os.Stdout
+	if path != "" {
+		var err error
+		outfile, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
 		defer outfile.Close()
 	}
 
 	outb := bufio.NewWriter(outfile)
 
-	_, err = outb.WriteString(headHead)
+	_, err := outb.WriteString(headHead)
 	if err != nil {
 		goto fail
 	}
@@ -395,7 +961,7 @@ func main() {
 		goto fail
 	}
 
-	err = ldb.SortedSave(outb, verbose)
+	err = save(outb, verbose)
 
 	if err != nil {
 		goto fail
@@ -404,13 +970,9 @@ func main() {
 	if err != nil {
 		goto fail
 	}
-	err = outb.Flush()
-	if err != nil {
-		goto fail
-	}
-	return
+	return outb.Flush()
 
 fail:
 	outb.Flush()
-	log.Fatal(err)
+	return err
 }
@@ -102,6 +102,15 @@ x86_64)
 esac
 
 
+#
+# Use the full corpus when it was shipped along, otherwise the model
+# built into license-extract
+#
+corpus=""
+if [ -e ${bindir}/CopyrightCorpus.in ] ; then
+	corpus="-corpus ${bindir}/CopyrightCorpus.in"
+fi
+
 #
 # Pick a verbosity level
 #
@@ -128,9 +137,11 @@ for pkgpath in $(ls ${dir}/*) ; do
 	mkdir -p "${aoutdir}/${pkgdir}"
 	cd ${pkgdir}
 	license-extract-EXTRACTVERSION-$host-$arch \
-		-corpus ${bindir}/CopyrightCorpus.in \
+		${corpus} \
 		-style ../style.css \
 		-ldir "${aoutdir}/${pkgdir}" \
+		-srcdir "${aoutdir}/${pkgdir}/source" \
+		-triage "${aoutdir}/${pkgdir}/${pkgdir}-triage.html" \
 		-verbose=${verbose} \
 		-o "${aoutdir}/${pkgdir}/${pkgdir}.html" \
 		"."
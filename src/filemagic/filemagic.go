@@ -28,6 +28,7 @@
 package filemagic
 
 import (
+	"fmt"
 	"log"
 	"os/exec"
 	"regexp"
@@ -95,13 +96,15 @@ func New(path string) (*Magic, error) {
 
 	magic, err := cmd.Output()
 	if err != nil {
-		log.Printf("%v: %s\n", cmd.Args, err)
-		magic = []byte("unknown")
-	} else {
-		if magic == nil || len(magic) == 0 {
-			log.Printf("%v: empty magic\n", cmd.Args)
-			magic = []byte("none")
+		exitErr, ok := err.(*exec.ExitError)
+		if ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%v: %s: %s", cmd.Args, err, strings.TrimSpace(string(exitErr.Stderr)))
 		}
+		return nil, fmt.Errorf("%v: %s", cmd.Args, err)
+	}
+	if magic == nil || len(magic) == 0 {
+		log.Printf("%v: empty magic\n", cmd.Args)
+		magic = []byte("none")
 	}
 
 	if magic[len(magic)-1] == '\n' {
@@ -0,0 +1,93 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package licensedb
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"notice"
+	"sort"
+)
+
+// Records a file the notice of which couldn't be made
+func (ldb *LicenseDB) AddError(e *notice.Error) {
+	ldb.Errors = append(ldb.Errors, e)
+}
+
+// Writes the files the notices of which couldn't be made, for the notices
+// document: how many of each kind of error there were and, by kind, the
+// files with what went wrong with each
+func (ldb *LicenseDB) SaveErrors(outb *bufio.Writer, verbose bool) error {
+	if len(ldb.Errors) == 0 {
+		return nil
+	}
+
+	errors := make([]*notice.Error, len(ldb.Errors))
+	copy(errors, ldb.Errors)
+	sort.Slice(errors, func(i, j int) bool {
+		if errors[i].Kind != errors[j].Kind {
+			return errors[i].Kind < errors[j].Kind
+		}
+		return errors[i].Path < errors[j].Path
+	})
+
+	_, err := fmt.Fprintf(outb, "<div class=\"errors\"> <!-- start errors -->\n"+
+		"	<h2>Errors</h2>\n"+
+		"	<p>%d files failed</p>\n", len(errors))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(errors); {
+		kind := errors[i].Kind
+		n := 0
+		for i+n < len(errors) && errors[i+n].Kind == kind {
+			n++
+		}
+		_, err = fmt.Fprintf(outb, "	<div class=\"errors-group\">\n"+
+			"		<h4>%s errors (%d)</h4>\n", kind, n)
+		if err != nil {
+			return err
+		}
+		for _, e := range errors[i : i+n] {
+			_, err = fmt.Fprintf(outb, "		<div class=\"errors-path\">%s <span class=\"errors-error\">%s</span></div>\n",
+				html.EscapeString(e.Path), html.EscapeString(e.Err.Error()))
+			if err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(outb, "	</div>\n")
+		if err != nil {
+			return err
+		}
+		i += n
+	}
+
+	_, err = fmt.Fprintf(outb, "</div> <!-- end errors -->\n")
+	return err
+}
@@ -39,6 +39,7 @@ import (
 	"path"
 	"regexp"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -52,6 +53,15 @@ type LicenseDB struct {
 	SortedNotices NoticeSlice      // sorted list of notices
 	Licenses      map[string]int   // pathnames of files containing licenses
 	LicenseDir    string           // Directory to save license files into
+	SourceDir     string           // Directory to save source pages into, see SaveSource
+	SourceLink    string           // SourceDir as the notices link to it
+	Triage        []TriageFile     // the files no copyright notice was found in, see SaveTriage
+	TriageLink    string           // the triage report as the notices link to it, "" for none
+	Errors        []*notice.Error  // the files the notices of which couldn't be made, see SaveErrors
+	Incomplete    string           // why the scan was cut short, "" if it wasn't, see SaveIncomplete
+	// Sort the notices by their confidence, the highest first, rather
+	// than by the path of their first file
+	SortByConfidence bool
 
 	//
 	// Statistics
@@ -63,6 +73,12 @@ type LicenseDB struct {
 	NumDupNotices uint64    // Total number of duplicate Notices held in this db
 	SearchHist    []uint32  // Histogram of bucket sizes
 	MaxSearch     int       // The most hashes that had to be compared to determine a dedup hit
+
+	NumFiles     uint64        // Total number of files added, licenses included
+	TypeCounts   []uint64      // Number of files added by notice Type
+	StatusCounts []uint64      // Number of files added by notice Status, licenses excluded
+	Timing       notice.Timing // Total time spent making the notices added
+	Slowest      []SlowFile    // The files the notices of which took the longest to make, see NumSlowest
 }
 
 //
@@ -106,6 +122,8 @@ func NewLicenseDB(licensedir string, nbuckets int, indexOffset int) *LicenseDB {
 		IndexOffset: indexOffset,
 		SearchHist:  make([]uint32, 1000),
 	}
+	ldb.TypeCounts = make([]uint64, notice.ERR+1)
+	ldb.StatusCounts = make([]uint64, notice.Unsupported+1)
 
 	return ldb
 }
@@ -129,11 +147,21 @@ func (ldb *LicenseDB) AddLicense(path string, verbose bool) {
 }
 
 func (ldb *LicenseDB) Add(path string, n *notice.Notice, verbose bool) {
+	ldb.count(path, n)
+
 	if IsLicense(path) {
 		ldb.AddLicense(path, verbose)
 		return
 	}
 
+	if n.Status != notice.Found {
+		if verbose {
+			log.Printf("[LDB] %s: %s\n", path, n.Status)
+		}
+		ldb.Triage = append(ldb.Triage, TriageFile{Path: path, Status: n.Status, Magic: n.Magic})
+		return
+	}
+
 	offset := ldb.IndexOffset
 	index := (int(n.Sha1[offset]) | (int(n.Sha1[offset+1]) << 8) | (int(n.Sha1[offset+2]) << 16) | (int(n.Sha1[offset+3]) << 24)) % ldb.NumBuckets
 
@@ -161,7 +189,7 @@ func (ldb *LicenseDB) Add(path string, n *notice.Notice, verbose bool) {
 	if ns > ldb.MaxSearch {
 		ldb.MaxSearch = ns
 		if verbose {
-			log.Printf("[HIST] MaxSearch %d\n", n)
+			log.Printf("[HIST] MaxSearch %d\n", ns)
 		}
 	}
 	if ns >= len(ldb.SearchHist) {
@@ -176,15 +204,15 @@ func (ldb *LicenseDB) Add(path string, n *notice.Notice, verbose bool) {
 			v.Count++
 			ldb.NumDupNotices++
 			found := false
-			for _, p := range v.Files {
-				if path == p {
+			for _, o := range v.Occurrences {
+				if path == o.Path {
 					found = true
 					break
 				}
 			}
 
 			if !found {
-				v.Files = append(v.Files, path)
+				v.Occurrences = append(v.Occurrences, notice.Occurrence{Path: path, Spans: n.Spans})
 			}
 
 			if verbose {
@@ -204,12 +232,12 @@ func (ldb *LicenseDB) Add(path string, n *notice.Notice, verbose bool) {
 		log.Printf("[LDB] %s: New Notice\n", path)
 	}
 
-	n.Files = append(n.Files, path)
+	n.Occurrences = append(n.Occurrences, notice.Occurrence{Path: path, Spans: n.Spans})
 	n.Next = *l
 	*l = n
 }
 
-func writeNotice(outb *bufio.Writer, n *notice.Notice, verbose bool) error {
+func (ldb *LicenseDB) writeNotice(outb *bufio.Writer, n *notice.Notice, verbose bool) error {
 	var err error
 
 	_, err = fmt.Fprintf(outb, "<div class=\"notice\"> <!-- start notice %v -->\n", n.Sha1)
@@ -221,13 +249,31 @@ func writeNotice(outb *bufio.Writer, n *notice.Notice, verbose bool) error {
 		return err
 	}
 
-	for _, path := range n.Files {
-		epath := html.EscapeString(path)
+	for _, o := range n.Occurrences {
 		if verbose {
-			log.Printf("[OUTPUT] %s\n", epath)
+			log.Printf("[OUTPUT] %s\n", o)
 		}
 
-		_, err = fmt.Fprintf(outb, "<div class=\"notice-path\">%s</div>\n", epath)
+		var spans []string
+		for _, span := range o.Spans {
+			spans = append(spans, span.String())
+		}
+		if link := ldb.sourceLink(o); link != "" {
+			_, err = fmt.Fprintf(outb, "<div class=\"notice-path\"><a href=\"%s\">%s</a>", html.EscapeString(link),
+				html.EscapeString(o.String()))
+		} else {
+			_, err = fmt.Fprintf(outb, "<div class=\"notice-path\">%s", html.EscapeString(o.String()))
+		}
+		if err != nil {
+			return err
+		}
+		if len(spans) != 0 {
+			_, err = fmt.Fprintf(outb, " <span class=\"notice-spans\">%s</span>", strings.Join(spans, ", "))
+			if err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(outb, "</div>\n")
 		if err != nil {
 			return err
 		}
@@ -238,6 +284,22 @@ func writeNotice(outb *bufio.Writer, n *notice.Notice, verbose bool) error {
 		return err
 	}
 
+	// only the notices of other languages than English say what they are in
+	if len(n.Languages) > 1 || (len(n.Languages) == 1 && n.Languages[0] != "en") {
+		_, err = fmt.Fprintf(outb, "<div class=\"notice-languages\">Languages: %s</div>\n",
+			html.EscapeString(strings.Join(n.Languages, ", ")))
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.Confidence != 0 {
+		_, err = fmt.Fprintf(outb, "<div class=\"notice-confidence\">Confidence: %.2f</div>\n", n.Confidence)
+		if err != nil {
+			return err
+		}
+	}
+
 	ltext := html.EscapeString(string(n.Text))
 
 	_, err = fmt.Fprintf(outb, "<div class=\"notice-text\"> <!-- start notice-text -->\n")
@@ -364,7 +426,7 @@ func (ldb *LicenseDB) SaveNotices(outb *bufio.Writer, verbose bool) error {
 					return err
 				}
 			}
-			err = writeNotice(outb, n, verbose)
+			err = ldb.writeNotice(outb, n, verbose)
 			if err != nil {
 				return err
 			}
@@ -380,8 +442,28 @@ func (ldb *LicenseDB) SaveNotices(outb *bufio.Writer, verbose bool) error {
 	return nil
 }
 
+//
+// Writes that the scan was cut short before all the files were done, and
+// why, if it was, so that a partial report can't be taken for a full one
+//
+func (ldb *LicenseDB) SaveIncomplete(outb *bufio.Writer, verbose bool) error {
+	if ldb.Incomplete == "" {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(outb, "<div class=\"incomplete\"> <!-- start incomplete -->\n"+
+		"	<p>Incomplete: the scan %s, the files it hadn't done yet are missing</p>\n"+
+		"</div> <!-- end incomplete -->\n", html.EscapeString(ldb.Incomplete))
+	return err
+}
+
 func (ldb *LicenseDB) Save(outb *bufio.Writer, verbose bool) error {
-	err := ldb.SaveLicenses(outb, verbose)
+	err := ldb.SaveIncomplete(outb, verbose)
+	if err != nil {
+		return err
+	}
+
+	err = ldb.SaveLicenses(outb, verbose)
 	if err != nil {
 		return err
 	}
@@ -391,6 +473,16 @@ func (ldb *LicenseDB) Save(outb *bufio.Writer, verbose bool) error {
 		return err
 	}
 
+	err = ldb.SaveTriageSummary(outb, verbose)
+	if err != nil {
+		return err
+	}
+
+	err = ldb.SaveErrors(outb, verbose)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -399,22 +491,53 @@ func (slice NoticeSlice) Len() int {
 }
 
 func (slice NoticeSlice) Less(i, j int) bool {
-	return slice[i].Files[0] < slice[j].Files[0]
+	return slice[i].Occurrences[0].Path < slice[j].Occurrences[0].Path
 }
 
 func (slice NoticeSlice) Swap(i, j int) {
 	slice[i], slice[j] = slice[j], slice[i]
 }
 
+// Sorts the occurrences of a notice by path
+type byPath []notice.Occurrence
+
+func (slice byPath) Len() int {
+	return len(slice)
+}
+
+func (slice byPath) Less(i, j int) bool {
+	return slice[i].Path < slice[j].Path
+}
+
+func (slice byPath) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+// Sorts notices by their confidence, the highest first, and then by path
+type byConfidence struct {
+	NoticeSlice
+}
+
+func (slice byConfidence) Less(i, j int) bool {
+	if slice.NoticeSlice[i].Confidence != slice.NoticeSlice[j].Confidence {
+		return slice.NoticeSlice[i].Confidence > slice.NoticeSlice[j].Confidence
+	}
+	return slice.NoticeSlice.Less(i, j)
+}
+
 func (ldb *LicenseDB) SaveSortedNotices(outb *bufio.Writer, verbose bool) error {
 	// sort
 	for i := 0; i < len(ldb.Notices); i++ {
 		for n := ldb.Notices[i]; n != nil; n = n.Next {
-			sort.Strings(n.Files) // sort the files of each notice
+			sort.Sort(byPath(n.Occurrences)) // sort the files of each notice
 			ldb.SortedNotices = append(ldb.SortedNotices, n)
 		}
 	}
-	sort.Sort(ldb.SortedNotices)
+	if ldb.SortByConfidence {
+		sort.Sort(byConfidence{ldb.SortedNotices})
+	} else {
+		sort.Sort(ldb.SortedNotices)
+	}
 
 	// Now that there is a sorted list iterate through and output to outb
 	const start = "<div class=\"notices\"> <!-- start notices -->\n" +
@@ -435,7 +558,7 @@ func (ldb *LicenseDB) SaveSortedNotices(outb *bufio.Writer, verbose bool) error
 				return err
 			}
 		}
-		err = writeNotice(outb, n, verbose)
+		err = ldb.writeNotice(outb, n, verbose)
 		if err != nil {
 			return err
 		}
@@ -452,7 +575,12 @@ func (ldb *LicenseDB) SaveSortedNotices(outb *bufio.Writer, verbose bool) error
 }
 
 func (ldb *LicenseDB) SortedSave(outb *bufio.Writer, verbose bool) error {
-	err := ldb.SaveLicenses(outb, verbose)
+	err := ldb.SaveIncomplete(outb, verbose)
+	if err != nil {
+		return err
+	}
+
+	err = ldb.SaveLicenses(outb, verbose)
 	if err != nil {
 		return err
 	}
@@ -462,5 +590,15 @@ func (ldb *LicenseDB) SortedSave(outb *bufio.Writer, verbose bool) error {
 		return err
 	}
 
+	err = ldb.SaveTriageSummary(outb, verbose)
+	if err != nil {
+		return err
+	}
+
+	err = ldb.SaveErrors(outb, verbose)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
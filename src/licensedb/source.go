@@ -0,0 +1,217 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+package licensedb
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"notice"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//
+// The source pages show each file a notice was found in, HTML escaped and
+// with line numbers, with the parts of it the notice was taken from
+// highlighted.  The notice-path entries link to them, at the line the
+// notice starts on.
+//
+
+const sourceHead = "<!DOCTYPE html>\n" +
+	"<html>\n" +
+	"<head>\n" +
+	"	<meta charset=\"UTF-8\">\n" +
+	"	<title>%s</title>\n" +
+	"	<style>\n" +
+	"	.line-number {\n" +
+	"		color: gray;\n" +
+	"		text-decoration: none;\n" +
+	"		user-select: none;\n" +
+	"	}\n" +
+	"	.notice-span {\n" +
+	"		background-color: #FFFF99;\n" +
+	"	}\n" +
+	"	.source-line:target {\n" +
+	"		background-color: #EAFFFF;\n" +
+	"	}\n" +
+	"	</style>\n" +
+	"</head>\n" +
+	"<body>\n" +
+	"<h2>%s</h2>\n" +
+	"<pre class=\"source\">\n"
+
+const sourceFooter = "</pre>\n" +
+	"</body>\n" +
+	"</html>\n"
+
+// Returns where the source page of the file at path goes, relative to
+// the source directory: the path cleaned, with ".html" added.  So that
+// the page stays in the directory and no two paths share one, each ".."
+// the path starts with is made "_up", the "/" of an absolute path
+// "_root", and a name after them that starts with "_" gets another.
+func sourcePage(p string) string {
+	p = path.Clean(filepath.ToSlash(p))
+	var page []string
+	if strings.HasPrefix(p, "/") {
+		page = append(page, "_root")
+		p = p[1:]
+	}
+	for p == ".." || strings.HasPrefix(p, "../") {
+		page = append(page, "_up")
+		p = strings.TrimPrefix(p[2:], "/")
+	}
+	if strings.HasPrefix(p, "_") {
+		p = "_" + p
+	}
+	return path.Join(append(page, p)...) + ".html"
+}
+
+// Returns the link to the source page of the occurrence, at the line the
+// notice starts on, or "" if there is no page for it
+func (ldb *LicenseDB) sourceLink(o notice.Occurrence) string {
+	if ldb.SourceDir == "" || len(o.Spans) == 0 {
+		return ""
+	}
+	link := &url.URL{Path: path.Join(ldb.SourceLink, sourcePage(o.Path)), Fragment: fmt.Sprintf("L%d", o.Spans[0].StartLine)}
+	return link.String()
+}
+
+// Writes the source page of the file at path that n was made from to
+// the SourceDir, if n is a notice it has one for: raw, the text of the
+// file, is read again if it is nil, as for a file that wasn't read this
+// time.  It is called as each notice is made, so that the text of the
+// files isn't kept until the end, and can be called concurrently.
+func (ldb *LicenseDB) SaveSource(path string, n *notice.Notice, raw []byte, verbose bool) error {
+	if ldb.SourceDir == "" || n.Status != notice.Found || len(n.Spans) == 0 || IsLicense(path) {
+		return nil
+	}
+	if raw == nil {
+		var err error
+		raw, err = ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+	}
+	o := notice.Occurrence{Path: path, Spans: n.Spans}
+
+	dst := filepath.Join(ldb.SourceDir, filepath.FromSlash(sourcePage(path)))
+	if verbose {
+		log.Printf("[SOURCE] %s: %s\n", path, dst)
+	}
+	err := os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+	dstf, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	outb := bufio.NewWriter(dstf)
+	err = writeSource(outb, o, raw)
+	if err == nil {
+		err = outb.Flush()
+	}
+	cerr := dstf.Close()
+	if err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// Writes the page of the file: a line of the page for each line of it,
+// with an id of "L" and its number, and the spans of the notice marked
+func writeSource(outb *bufio.Writer, o notice.Occurrence, raw []byte) error {
+	epath := html.EscapeString(o.Path)
+	_, err := fmt.Fprintf(outb, sourceHead, epath, epath)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(raw), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	width := len(fmt.Sprint(len(lines)))
+
+	// start is the offset of the line in raw, the spans being in offsets
+	// of raw
+	start := 0
+	spans := o.Spans
+	for number, line := range lines {
+		end := start + len(line)
+		line = strings.TrimRight(line, "\r\n")
+
+		var text strings.Builder
+		lineOffset := start
+		for lineOffset < start+len(line) {
+			for len(spans) > 0 && spans[0].End <= lineOffset {
+				spans = spans[1:]
+			}
+			if len(spans) == 0 || spans[0].Start >= start+len(line) {
+				text.WriteString(escapeSource(line[lineOffset-start:]))
+				break
+			}
+
+			markStart := spans[0].Start
+			if markStart < lineOffset {
+				markStart = lineOffset
+			}
+			markEnd := spans[0].End
+			if markEnd > start+len(line) {
+				markEnd = start + len(line)
+			}
+			text.WriteString(escapeSource(line[lineOffset-start : markStart-start]))
+			text.WriteString("<mark class=\"notice-span\">" + escapeSource(line[markStart-start:markEnd-start]) + "</mark>")
+			lineOffset = markEnd
+		}
+
+		_, err = fmt.Fprintf(outb, "<span class=\"source-line\" id=\"L%d\"><a class=\"line-number\" href=\"#L%d\">%*d</a>  %s</span>\n",
+			number+1, number+1, width, number+1, text.String())
+		if err != nil {
+			return err
+		}
+		start = end
+	}
+
+	_, err = outb.WriteString(sourceFooter)
+	return err
+}
+
+// HTML escapes text of a source file, which needn't be UTF-8
+func escapeSource(text string) string {
+	return html.EscapeString(strings.ToValidUTF8(text, "\uFFFD"))
+}
@@ -0,0 +1,216 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package licensedb
+
+import (
+	"fmt"
+	"io"
+	"notice"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How many of the slowest files the statistics keep
+const NumSlowest = 10
+
+// A file, and how long making its notice took
+type SlowFile struct {
+	Path    string  `json:"path"`
+	Seconds float64 `json:"seconds"`
+}
+
+//
+// Stats summarizes a run from the statistics of the LicenseDB, for the
+// human readable summary (WriteText) or as JSON
+//
+type Stats struct {
+	Incomplete     string            `json:"incomplete,omitempty"` // see LicenseDB.Incomplete
+	Seconds        float64           `json:"seconds"`
+	Files          uint64            `json:"files"`
+	FilesPerSecond float64           `json:"files_per_second"`
+	Types          map[string]uint64 `json:"types"`    // files by notice type
+	Statuses       map[string]uint64 `json:"statuses"` // files by notice status, licenses excluded
+	Licenses       int               `json:"licenses"`
+	Errors         map[string]uint64 `json:"errors"` // files that failed by kind of error
+	Notices        uint64            `json:"notices"`
+	UniqueNotices  uint64            `json:"unique_notices"`
+	DedupRatio     float64           `json:"dedup_ratio"` // of the notices found, the share that were duplicates
+	CacheHits      uint64            `json:"cache_hits"`
+	CacheMisses    uint64            `json:"cache_misses"`
+	Buckets        int               `json:"buckets"`
+	MaxSearch      int               `json:"max_search"`
+	SearchHist     []uint32          `json:"search_hist"` // see LicenseDB.SearchHist, without the trailing zeros
+	Time           StatsTime         `json:"time"`
+	Slowest        []SlowFile        `json:"slowest"`
+}
+
+// The total time, in seconds, spent in each step of making the notices
+type StatsTime struct {
+	Magic    float64 `json:"magic"`
+	Comments float64 `json:"comments"`
+	Tagging  float64 `json:"tagging"`
+}
+
+// Counts the file at path, of notice n, in the statistics
+func (ldb *LicenseDB) count(path string, n *notice.Notice) {
+	ldb.NumFiles++
+	ldb.TypeCounts[n.Type]++
+	if !IsLicense(path) {
+		ldb.StatusCounts[n.Status]++
+	}
+
+	ldb.Timing.Magic += n.Timing.Magic
+	ldb.Timing.Comments += n.Timing.Comments
+	ldb.Timing.Tagging += n.Timing.Tagging
+
+	elapsed := n.Timing.Magic + n.Timing.Comments + n.Timing.Tagging
+	if elapsed == 0 {
+		return
+	}
+	seconds := elapsed.Seconds()
+	i := sort.Search(len(ldb.Slowest), func(i int) bool {
+		return ldb.Slowest[i].Seconds < seconds
+	})
+	if i == NumSlowest {
+		return
+	}
+	if len(ldb.Slowest) < NumSlowest {
+		ldb.Slowest = append(ldb.Slowest, SlowFile{})
+	}
+	copy(ldb.Slowest[i+1:], ldb.Slowest[i:])
+	ldb.Slowest[i] = SlowFile{Path: path, Seconds: seconds}
+}
+
+// Returns the statistics of the run so far
+func (ldb *LicenseDB) Stats() *Stats {
+	stats := &Stats{
+		Incomplete:    ldb.Incomplete,
+		Seconds:       time.Since(ldb.CreateTime).Seconds(),
+		Files:         ldb.NumFiles,
+		Types:         make(map[string]uint64),
+		Statuses:      make(map[string]uint64),
+		Licenses:      len(ldb.Licenses),
+		Errors:        make(map[string]uint64),
+		Notices:       ldb.NumNotices,
+		UniqueNotices: ldb.NumNotices - ldb.NumDupNotices,
+		Buckets:       ldb.NumBuckets,
+		MaxSearch:     ldb.MaxSearch,
+		Time: StatsTime{
+			Magic:    ldb.Timing.Magic.Seconds(),
+			Comments: ldb.Timing.Comments.Seconds(),
+			Tagging:  ldb.Timing.Tagging.Seconds(),
+		},
+		Slowest: append([]SlowFile{}, ldb.Slowest...),
+	}
+	if stats.Seconds > 0 {
+		stats.FilesPerSecond = float64(stats.Files) / stats.Seconds
+	}
+	for ltype, count := range ldb.TypeCounts {
+		if count > 0 {
+			stats.Types[notice.TypeName(ltype)] = count
+		}
+	}
+	for status, count := range ldb.StatusCounts {
+		if count > 0 {
+			stats.Statuses[notice.Status(status).String()] = count
+		}
+	}
+	for _, e := range ldb.Errors {
+		stats.Errors[e.Kind.String()]++
+	}
+	if ldb.NumNotices > 0 {
+		stats.DedupRatio = float64(ldb.NumDupNotices) / float64(ldb.NumNotices)
+	}
+	last := len(ldb.SearchHist)
+	for last > 0 && ldb.SearchHist[last-1] == 0 {
+		last--
+	}
+	stats.SearchHist = append([]uint32{}, ldb.SearchHist[:last]...)
+
+	return stats
+}
+
+// Writes the statistics as a human readable summary
+func (stats *Stats) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	if stats.Incomplete != "" {
+		fmt.Fprintf(&b, "Incomplete: the scan %s\n", stats.Incomplete)
+	}
+	fmt.Fprintf(&b, "Files:          %d in %s, %.1f files/s\n", stats.Files,
+		(time.Duration(stats.Seconds * float64(time.Second))).Round(time.Millisecond), stats.FilesPerSecond)
+	fmt.Fprintf(&b, "  by type:      %s\n", formatCounts(stats.Types))
+	fmt.Fprintf(&b, "  by status:    %s\n", formatCounts(stats.Statuses))
+	fmt.Fprintf(&b, "  licenses:     %d\n", stats.Licenses)
+	fmt.Fprintf(&b, "  errors:       %s\n", formatCounts(stats.Errors))
+	fmt.Fprintf(&b, "Notices:        %d, %d unique, %.1f%% duplicates\n", stats.Notices, stats.UniqueNotices,
+		100*stats.DedupRatio)
+	fmt.Fprintf(&b, "Cache:          %d hits, %d misses\n", stats.CacheHits, stats.CacheMisses)
+	fmt.Fprintf(&b, "Buckets:        %d, at most %d compared\n", stats.Buckets, stats.MaxSearch)
+	fmt.Fprintf(&b, "  compared:    ")
+	for i, count := range stats.SearchHist {
+		if count > 0 {
+			fmt.Fprintf(&b, " %d: %d", i, count)
+		}
+	}
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "Time:           magic %.2fs, comments %.2fs, tagging %.2fs\n", stats.Time.Magic,
+		stats.Time.Comments, stats.Time.Tagging)
+	fmt.Fprintf(&b, "Slowest files:\n")
+	for _, f := range stats.Slowest {
+		fmt.Fprintf(&b, "  %8.3fs %s\n", f.Seconds, f.Path)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Formats counts by name, the largest first, as "name count, ..."
+func formatCounts(counts map[string]uint64) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,216 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+package licensedb
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"notice"
+	"path/filepath"
+	"sort"
+)
+
+//
+// The triage report lists the files no copyright notice was found in,
+// grouped by directory and, for those of types that aren't looked at,
+// by type, so that the ones that need attention can be found.
+//
+
+// A file no copyright notice was found in, and why
+type TriageFile struct {
+	Path   string
+	Status notice.Status // NoNotice or Unsupported
+	Magic  string        // the type file(1) gives an Unsupported file
+}
+
+// Files of the triage grouped by a key, the directory or the type
+type triageGroup struct {
+	key   string
+	files []TriageFile
+}
+
+// Returns the files grouped by key, the groups with the most files first
+// and those with as many in key order, and the files of each in path order
+func groupTriage(files []TriageFile, key func(f TriageFile) string) []triageGroup {
+	index := make(map[string]int)
+	var groups []triageGroup
+	for _, f := range files {
+		k := key(f)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, triageGroup{key: k})
+		}
+		groups[i].files = append(groups[i].files, f)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].files) != len(groups[j].files) {
+			return len(groups[i].files) > len(groups[j].files)
+		}
+		return groups[i].key < groups[j].key
+	})
+	for _, group := range groups {
+		sort.Slice(group.files, func(i, j int) bool {
+			return group.files[i].Path < group.files[j].Path
+		})
+	}
+	return groups
+}
+
+// Returns how many of the files have each status
+func countTriage(files []TriageFile) (noNotice int, unsupported int) {
+	for _, f := range files {
+		if f.Status == notice.Unsupported {
+			unsupported++
+		} else {
+			noNotice++
+		}
+	}
+	return noNotice, unsupported
+}
+
+// Writes how many files had no copyright notice found in them, for the
+// notices document, linking to the triage report if there is one
+func (ldb *LicenseDB) SaveTriageSummary(outb *bufio.Writer, verbose bool) error {
+	noNotice, unsupported := countTriage(ldb.Triage)
+	if noNotice == 0 && unsupported == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(outb, "<div class=\"triage-summary\"> <!-- start triage summary -->\n"+
+		"	<p>%d files with no copyright notice, %d files of unsupported types", noNotice, unsupported)
+	if err != nil {
+		return err
+	}
+	if ldb.TriageLink != "" {
+		_, err = fmt.Fprintf(outb, " (<a href=\"%s\">triage report</a>)", html.EscapeString(ldb.TriageLink))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(outb, "</p>\n</div> <!-- end triage summary -->\n")
+	return err
+}
+
+// Writes the triage report: the files no copyright notice was found in by
+// directory, with how many of each status are in each, and the files of
+// unsupported types by type
+func (ldb *LicenseDB) SaveTriage(outb *bufio.Writer, verbose bool) error {
+	err := ldb.SaveIncomplete(outb, verbose)
+	if err != nil {
+		return err
+	}
+
+	noNotice, unsupported := countTriage(ldb.Triage)
+	_, err = fmt.Fprintf(outb, "<div class=\"triage\"> <!-- start triage -->\n"+
+		"	<h2>Triage</h2>\n"+
+		"	<p>%d files with no copyright notice, %d files of unsupported types</p>\n", noNotice, unsupported)
+	if err != nil {
+		return err
+	}
+
+	byDirectory := groupTriage(ldb.Triage, func(f TriageFile) string {
+		return filepath.Dir(f.Path)
+	})
+	_, err = fmt.Fprintf(outb, "	<h3>By directory</h3>\n"+
+		"	<table>\n"+
+		"		<tr><th>Directory</th><th>No notice</th><th>Unsupported</th></tr>\n")
+	if err != nil {
+		return err
+	}
+	for _, group := range byDirectory {
+		groupNoNotice, groupUnsupported := countTriage(group.files)
+		_, err = fmt.Fprintf(outb, "		<tr><td>%s</td><td>%d</td><td>%d</td></tr>\n",
+			html.EscapeString(group.key), groupNoNotice, groupUnsupported)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(outb, "	</table>\n")
+	if err != nil {
+		return err
+	}
+	for _, group := range byDirectory {
+		err = writeTriageGroup(outb, group, func(f TriageFile) string {
+			if f.Status == notice.Unsupported {
+				return f.Magic
+			}
+			return f.Status.String()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var unsupportedFiles []TriageFile
+	for _, f := range ldb.Triage {
+		if f.Status == notice.Unsupported {
+			unsupportedFiles = append(unsupportedFiles, f)
+		}
+	}
+	_, err = fmt.Fprintf(outb, "	<h3>Unsupported types</h3>\n")
+	if err != nil {
+		return err
+	}
+	for _, group := range groupTriage(unsupportedFiles, func(f TriageFile) string { return f.Magic }) {
+		err = writeTriageGroup(outb, group, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(outb, "</div> <!-- end triage -->\n")
+	return err
+}
+
+// Writes a group of the triage report, with what about each file of it
+// says if about isn't nil
+func writeTriageGroup(outb *bufio.Writer, group triageGroup, about func(f TriageFile) string) error {
+	_, err := fmt.Fprintf(outb, "	<div class=\"triage-group\">\n"+
+		"		<h4>%s (%d)</h4>\n", html.EscapeString(group.key), len(group.files))
+	if err != nil {
+		return err
+	}
+	for _, f := range group.files {
+		if about != nil {
+			_, err = fmt.Fprintf(outb, "		<div class=\"triage-path\">%s <span class=\"triage-status\">%s</span></div>\n",
+				html.EscapeString(f.Path), html.EscapeString(about(f)))
+		} else {
+			_, err = fmt.Fprintf(outb, "		<div class=\"triage-path\">%s</div>\n", html.EscapeString(f.Path))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(outb, "	</div>\n")
+	return err
+}
@@ -0,0 +1,77 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package notice
+
+import (
+	"fmt"
+	"os"
+)
+
+// A failure to make the notice of a file, of one of the kinds of
+// ErrorKind, so that the failures of a run can be told apart and reported
+type Error struct {
+	Path string
+	Kind ErrorKind
+	Err  error
+}
+
+type ErrorKind int
+
+const (
+	// The file couldn't be read, or the directory it is in walked
+	ReadError ErrorKind = iota
+	// file(1) couldn't tell the type of the file
+	MagicError
+	// The tagger matched a copyright notice in the file, but then
+	// couldn't find it
+	TaggerError
+	// Making the notice of the file panicked
+	PanicError
+)
+
+var errorKindNames = []string{"read", "magic", "tagger", "panic"}
+
+func (kind ErrorKind) String() string {
+	return errorKindNames[kind]
+}
+
+// Returns err as an Error of path of the kind given, or as it is if it
+// already is one.  The path of an os.PathError is not repeated.
+func NewError(path string, kind ErrorKind, err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	if e, ok := err.(*os.PathError); ok && e.Path == path {
+		err = fmt.Errorf("%s: %s", e.Op, e.Err)
+	}
+	return &Error{Path: path, Kind: kind, Err: err}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s error: %s", e.Path, e.Kind, e.Err)
+}
@@ -0,0 +1,301 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+package notice
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"tagger"
+)
+
+// How much of a comment with a copyright notice in it goes into the
+// notice text
+type Granularity int
+
+const (
+	// The copyright statements alone, each on a line of its own: the
+	// attribution text and nothing else
+	Statements Granularity = iota
+	// The paragraphs of the comment with copyright statements in them,
+	// and the paragraph after each, which is usually the license
+	Paragraphs
+	// The whole comment, as it is in the file
+	Comments
+)
+
+var granularityNames = []string{"statement", "paragraph", "comment"}
+
+func (granularity Granularity) String() string {
+	return granularityNames[granularity]
+}
+
+// Returns the granularity by its name: statement, paragraph or comment
+func ParseGranularity(name string) (Granularity, error) {
+	for granularity, granularityName := range granularityNames {
+		if name == granularityName {
+			return Granularity(granularity), nil
+		}
+	}
+	return Statements, fmt.Errorf("unknown notice granularity %q, not statement, paragraph or comment", name)
+}
+
+// The decoration of a line of a comment: what starts and ends it in the
+// comment styles of rcomment, and the space around that
+var (
+	commentLead  = regexp.MustCompile(`^[ \t]*(/\*+|\*+|//+|#+|dnl\b|<!--+|"""|\.\\")?[ \t]?`)
+	commentTrail = regexp.MustCompile(`[ \t]*(\*+/|-+->|""")?[ \t\r]*$`)
+)
+
+// The C preprocessor directives, which rcomment takes for shell comments;
+// "# if" and the like with a space are left to shell comments
+var preprocessorLine = regexp.MustCompile(`^[ \t]*#[ \t]*(define|undef|ifdef|ifndef|elif|endif|pragma)\b|^[ \t]*#(include|if|else|error)\b`)
+
+// Returns the text of the comment to put in the notice, the notices in it,
+// and the start and end of each part of the comment the text is made of;
+// nothing if there is no notice in it.  The notices are found in the text
+// of the comment without its decoration, which is only tagged once, and
+// their offsets are in the comment.
+func (granularity Granularity) noticeText(comment []byte, copyrightTagger *tagger.Tagger) ([]byte, []tagger.Notice, [][]int) {
+	text, offsets := uncomment(comment)
+	textNotices := copyrightTagger.FindAll(text)
+	if len(textNotices) == 0 {
+		return nil, nil, nil
+	}
+	notices := commentNotices(textNotices, offsets)
+
+	if granularity == Comments {
+		return comment, notices, [][]int{trimmedRange(comment)}
+	}
+
+	if granularity == Paragraphs {
+		paragraphs := paragraphsOf(text, textNotices)
+		var kept [][]byte
+		for _, paragraph := range paragraphs {
+			kept = append(kept, text[paragraph[0]:paragraph[1]])
+		}
+		return bytes.Join(kept, []byte("\n\n")), notices, commentRanges(paragraphs, offsets)
+	}
+
+	var statements [][]int
+	for _, n := range textNotices {
+		start, end := statementOf(text, n.Start, n.End, copyrightTagger.Prefilter)
+		// notices in the same sentence make one statement
+		if last := len(statements) - 1; last >= 0 && start < statements[last][1] {
+			start = statements[last][0]
+			if end < statements[last][1] {
+				end = statements[last][1]
+			}
+			statements = statements[:last]
+		}
+		statements = append(statements, []int{start, end})
+	}
+	var kept [][]byte
+	for _, statement := range statements {
+		kept = append(kept, bytes.Join(bytes.Fields(text[statement[0]:statement[1]]), []byte(" ")))
+	}
+	return bytes.Join(kept, []byte("\n")), notices, commentRanges(statements, offsets)
+}
+
+// The start and end of the text without the space around it
+func trimmedRange(text []byte) []int {
+	start := len(text) - len(bytes.TrimLeft(text, " \t\r\n"))
+	end := len(bytes.TrimRight(text, " \t\r\n"))
+	if end < start {
+		end = start
+	}
+	return []int{start, end}
+}
+
+// Returns the ranges of the text of uncomment as ranges of the comment,
+// given the offsets it returned
+func commentRanges(ranges [][]int, offsets []int) [][]int {
+	var commentRanges [][]int
+	for _, r := range ranges {
+		commentRanges = append(commentRanges, []int{offsets[r[0]], offsets[r[1]-1] + 1})
+	}
+	return commentRanges
+}
+
+// Returns the notices found in the text of uncomment as notices of the
+// comment, given the offsets it returned
+func commentNotices(notices []tagger.Notice, offsets []int) []tagger.Notice {
+	var commentNotices []tagger.Notice
+	for _, n := range notices {
+		n.Start, n.End = offsets[n.Start], offsets[n.End-1]+1
+		commentNotices = append(commentNotices, n)
+	}
+	return commentNotices
+}
+
+// Returns the text of the comment without its decoration, "//", "#", the
+// "*" starting each line of a block comment and the like, and the offset
+// in the comment of each byte of the text.  Lines that are blank but for
+// the decoration, a "#!" line or a C preprocessor directive, separate
+// paragraphs: a single blank line is left between paragraphs, and none
+// before the first or after the last.  The newlines of the text are at
+// the end of the line before them.
+func uncomment(comment []byte) ([]byte, []int) {
+	var text []byte
+	var offsets []int
+	blank := true
+	lineStart := 0
+	for _, line := range bytes.Split(comment, []byte("\n")) {
+		var content []byte
+		start := 0
+		if !bytes.HasPrefix(bytes.TrimSpace(line), []byte("#!")) && !preprocessorLine.Match(line) {
+			end := commentTrail.FindIndex(line)[0]
+			start = commentLead.FindIndex(line[:end])[1]
+			content = bytes.TrimRight(line[start:end], " \t\r")
+		}
+
+		if len(content) != 0 {
+			if len(text) != 0 {
+				lastEnd := offsets[len(offsets)-1] + 1
+				text = append(text, '\n')
+				offsets = append(offsets, lastEnd)
+				if blank {
+					text = append(text, '\n')
+					offsets = append(offsets, lastEnd)
+				}
+			}
+			text = append(text, content...)
+			for i := range content {
+				offsets = append(offsets, lineStart+start+i)
+			}
+		}
+		blank = len(content) == 0
+		lineStart += len(line) + 1
+	}
+	return text, offsets
+}
+
+// Returns the statement the notice from start to end is in: from the
+// start of its sentence to the end of it, but not past the lines the
+// notice is on, unless the last ends in a comma or "and", nor the end of
+// its paragraph.  Of the sentences of the notice that end lines, those
+// after the first with no hit of the prefilter in it are left out, such
+// as "This file is part of the GNU C Library." after the holder.
+func statementOf(text []byte, start int, end int, prefilter *tagger.Prefilter) (int, int) {
+	if paragraphEnd := bytes.Index(text[start:end], []byte("\n\n")); paragraphEnd >= 0 {
+		end = start + paragraphEnd
+	}
+	if prefilter != nil {
+		for lineEnd := start; lineEnd < end; lineEnd++ {
+			if text[lineEnd] != '\n' || !endsSentence(text, lineEnd) {
+				continue
+			}
+			nextEnd := bytes.IndexByte(text[lineEnd+1:end], '\n')
+			if nextEnd < 0 {
+				nextEnd = end - lineEnd - 1
+			}
+			if len(prefilter.FindAllIndex(text[lineEnd+1:lineEnd+1+nextEnd])) == 0 {
+				end = lineEnd
+				break
+			}
+		}
+	}
+
+	lineStart := bytes.LastIndexByte(text[:start], '\n') + 1
+	for curByte := start - 1; curByte > lineStart; curByte-- {
+		if endsSentence(text, curByte) {
+			lineStart = curByte
+			break
+		}
+	}
+	for lineStart < start && (text[lineStart] == ' ' || text[lineStart] == '\t') {
+		lineStart++
+	}
+
+	for end < len(text) && !endsSentence(text, end) {
+		if text[end] == '\n' && (!continuesLine(text[:end]) || bytes.HasPrefix(text[end:], []byte("\n\n"))) {
+			break
+		}
+		end++
+	}
+	return lineStart, end
+}
+
+// Whether the line the text ends in goes on to the next: it ends in a
+// comma or "and", as in "Copyright 2015 Exablox Corporation,\nand Acme Ltd."
+func continuesLine(text []byte) bool {
+	return bytes.HasSuffix(text, []byte(",")) || bytes.HasSuffix(text, []byte(" and")) ||
+		bytes.HasSuffix(text, []byte(" &"))
+}
+
+// Whether a sentence of the text ends just before offset: the byte before
+// it ends a sentence, and it is the end of the text or a space
+func endsSentence(text []byte, offset int) bool {
+	if offset == 0 || bytes.IndexByte([]byte(".!?"), text[offset-1]) < 0 {
+		return false
+	}
+	return offset == len(text) || bytes.IndexByte([]byte(" \t\r\n"), text[offset]) >= 0
+}
+
+// Returns end, the end of a notice, or the end of the sentence if that is
+// the byte after it, as for the "." after "All rights reserved", which the
+// notice doesn't take in
+func sentenceEnd(text []byte, end int) int {
+	if end < len(text) && endsSentence(text, end+1) {
+		return end + 1
+	}
+	return end
+}
+
+// Returns the start and end of the paragraphs of the text with the
+// notices in them, and of the paragraph after each of them.  Paragraphs
+// are separated by blank lines in the text.
+func paragraphsOf(text []byte, notices []tagger.Notice) [][]int {
+	var paragraphs [][]int
+	for start := 0; start < len(text); {
+		end := bytes.Index(text[start:], []byte("\n\n"))
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		paragraphs = append(paragraphs, []int{start, end})
+		start = end + len("\n\n")
+	}
+
+	var kept [][]int
+	n := 0
+	after := false
+	for _, paragraph := range paragraphs {
+		holds := false
+		for ; n < len(notices) && notices[n].Start < paragraph[1]; n++ {
+			holds = true
+		}
+		if holds || after {
+			kept = append(kept, paragraph)
+		}
+		after = holds
+	}
+	return kept
+}
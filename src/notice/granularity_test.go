@@ -0,0 +1,190 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+package notice
+
+import (
+	"log"
+	"os"
+	"tagger"
+	"testing"
+)
+
+var copyrightTagger *tagger.Tagger
+
+func TestMain(m *testing.M) {
+	var err error
+
+	copyrightTagger, err = tagger.Default()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// A span of a notice, by its lines and the text of the file it covers
+type spanTest struct {
+	startLine int
+	endLine   int
+	text      string
+}
+
+func TestGranularity(t *testing.T) {
+	tests := []struct {
+		name        string
+		granularity Granularity
+		raw         string
+		text        string
+		spans       []spanTest
+	}{
+		{"crlf", Statements,
+			"/*\r\n * Copyright (c) 2001 Jane Doe.\r\n * All rights reserved.\r\n */\r\nint x;\r\n",
+			"Copyright (c) 2001 Jane Doe.\nAll rights reserved.\n",
+			[]spanTest{{2, 3, "Copyright (c) 2001 Jane Doe.\r\n * All rights reserved."}}},
+		{"crlf", Statements,
+			"// Copyright 2001 Jane Doe.\r\n// Licensed under the MIT license.\r\n",
+			"Copyright 2001 Jane Doe.\n",
+			[]spanTest{{1, 1, "Copyright 2001 Jane Doe."}}},
+		{"crlf", Comments,
+			"int x;\r\n/* Copyright 2001 Jane Doe. */\r\n",
+			"/* Copyright 2001 Jane Doe. */\n",
+			[]spanTest{{2, 2, "/* Copyright 2001 Jane Doe. */"}}},
+		{"#", Statements,
+			"#!/bin/sh\n# Copyright 2001 Jane Doe.\n# Licensed under the MIT license.\n\nx=1\n",
+			"Copyright 2001 Jane Doe.\n",
+			[]spanTest{{2, 2, "Copyright 2001 Jane Doe."}}},
+		{"#", Paragraphs,
+			"#!/bin/sh\n# Copyright 2001 Jane Doe.\n# Licensed under the MIT license.\n\nx=1\n",
+			"Copyright 2001 Jane Doe.\nLicensed under the MIT license.\n",
+			[]spanTest{{2, 3, "Copyright 2001 Jane Doe.\n# Licensed under the MIT license."}}},
+		{"//", Paragraphs,
+			"// Copyright 2001 Jane Doe.\n//\n// Use of this source code is governed by a BSD-style license.\n\npackage x\n",
+			"Copyright 2001 Jane Doe.\n\nUse of this source code is governed by a BSD-style license.\n",
+			[]spanTest{{1, 1, "Copyright 2001 Jane Doe."}, {3, 3, "Use of this source code is governed by a BSD-style license."}}},
+		{"//", Comments,
+			"package x\n\n  // Copyright 2001 Jane Doe.\n  // Licensed under the MIT license.\n",
+			"  // Copyright 2001 Jane Doe.\n  // Licensed under the MIT license.\n\n",
+			[]spanTest{{3, 4, "// Copyright 2001 Jane Doe.\n  // Licensed under the MIT license."}}},
+		{"/* */", Statements,
+			"int x; /* Copyright 2001 Jane Doe. */ int y;\n",
+			"Copyright 2001 Jane Doe.\n",
+			[]spanTest{{1, 1, "Copyright 2001 Jane Doe."}}},
+		{"/* */", Paragraphs,
+			"/*\n * Copyright 2001 Jane Doe.\n *\n * Licensed under the MIT license.\n *\n * Some documentation.\n */\n",
+			"Copyright 2001 Jane Doe.\n\nLicensed under the MIT license.\n",
+			[]spanTest{{2, 2, "Copyright 2001 Jane Doe."}, {4, 4, "Licensed under the MIT license."}}},
+		{"docstring", Statements,
+			"\"\"\"\nCopyright 2001 Jane Doe.\n\"\"\"\nimport os\n",
+			"Copyright 2001 Jane Doe.\n",
+			[]spanTest{{2, 2, "Copyright 2001 Jane Doe."}}},
+		{"docstring", Comments,
+			"def f():\n    \"\"\"\n    Copyright 2001 Jane Doe.\n    \"\"\"\n",
+			"    \"\"\"\n    Copyright 2001 Jane Doe.\n    \"\"\"\n",
+			[]spanTest{{2, 4, "\"\"\"\n    Copyright 2001 Jane Doe.\n    \"\"\""}}},
+		{"preprocessor", Statements,
+			"#include <x.h>\n#define COPYRIGHT_H 1\n# Copyright 2001 Jane Doe.\n#endif\n",
+			"Copyright 2001 Jane Doe.\n",
+			[]spanTest{{3, 3, "Copyright 2001 Jane Doe."}}},
+		{"preprocessor", Paragraphs,
+			"# Copyright 2001 Jane Doe.\n#ifdef X\n# Licensed under the MIT license.\n",
+			"Copyright 2001 Jane Doe.\n\nLicensed under the MIT license.\n",
+			[]spanTest{{1, 1, "Copyright 2001 Jane Doe."}, {3, 3, "Licensed under the MIT license."}}},
+		{"no comments", Statements,
+			"Copyright 2001 Jane Doe.\nAll rights reserved.\n",
+			"Copyright 2001 Jane Doe.\nAll rights reserved.\n",
+			[]spanTest{{1, 2, "Copyright 2001 Jane Doe.\nAll rights reserved."}}},
+		{"no comments", Statements,
+			"Copyright 2001 Jane Doe.\r\nAll rights reserved.\r\n",
+			"Copyright 2001 Jane Doe.\nAll rights reserved.\n",
+			[]spanTest{{1, 2, "Copyright 2001 Jane Doe.\r\nAll rights reserved."}}},
+	}
+
+	for _, test := range tests {
+		n, err := newNotice("test", SRC, []byte(test.raw), 0, false, false, copyrightTagger, test.granularity)
+		if err != nil {
+			t.Errorf("%s, %s: %q: %v", test.name, test.granularity, test.raw, err)
+			continue
+		}
+		if string(n.Text) != test.text {
+			t.Errorf("%s, %s: %q: expected the text %q got %q", test.name, test.granularity, test.raw, test.text, n.Text)
+		}
+		if len(n.Spans) != len(test.spans) {
+			t.Errorf("%s, %s: %q: expected %d spans got %v", test.name, test.granularity, test.raw, len(test.spans), n.Spans)
+			continue
+		}
+		for i, span := range n.Spans {
+			expected := test.spans[i]
+			if span.StartLine != expected.startLine || span.EndLine != expected.endLine || test.raw[span.Start:span.End] != expected.text {
+				t.Errorf("%s, %s: %q: expected lines %d-%d %q got %s %q", test.name, test.granularity, test.raw,
+					expected.startLine, expected.endLine, expected.text, span, test.raw[span.Start:span.End])
+			}
+		}
+	}
+}
+
+func TestUncomment(t *testing.T) {
+	tests := []struct {
+		comment string
+		text    string
+	}{
+		{"// one\r\n// two\r\n", "one\ntwo"},
+		{"/*\n * one\n *\n * two\n */", "one\n\ntwo"},
+		{"/** one */", "one"},
+		{"  # one\n  #\n  #\n  # two\n", "one\n\ntwo"},
+		{"#!/bin/sh\n# one\n", "one"},
+		{"# one\n#define X 1\n# two\n", "one\n\ntwo"},
+		{"\"\"\"\none\n\"\"\"", "one"},
+		{"<!-- one -->", "one"},
+		{"dnl one\ndnl two\n", "one\ntwo"},
+	}
+
+	for _, test := range tests {
+		text, offsets := uncomment([]byte(test.comment))
+		if string(text) != test.text {
+			t.Errorf("%q: expected %q got %q", test.comment, test.text, text)
+		}
+		if len(offsets) != len(text) {
+			t.Errorf("%q: %d offsets for %d bytes", test.comment, len(offsets), len(text))
+			continue
+		}
+		// each byte of the text is the byte of the comment at its offset,
+		// but for the newlines, which are at the end of the line before,
+		// the two between paragraphs both
+		for i := range text {
+			if text[i] == '\n' {
+				if i == 0 || (text[i-1] != '\n' && offsets[i] != offsets[i-1]+1) ||
+					(text[i-1] == '\n' && offsets[i] != offsets[i-1]) {
+					t.Errorf("%q: the newline at %d is at %d", test.comment, i, offsets[i])
+				}
+			} else if test.comment[offsets[i]] != text[i] {
+				t.Errorf("%q: byte %d %q is at %d, %q", test.comment, i, text[i], offsets[i], test.comment[offsets[i]])
+			}
+		}
+	}
+}
@@ -29,13 +29,16 @@
 package notice
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"filemagic"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"regexp"
+	"runtime/debug"
 	"tagger"
+	"time"
 )
 
 const (
@@ -45,23 +48,130 @@ const (
 	ERR
 )
 
+var typeNames = []string{"source", "binary", "unknown", "error"}
+
+// The name of a Type of notice, for statistics
+func TypeName(ltype int) string {
+	return typeNames[ltype]
+}
+
 type Notice struct {
-	Sha1 [sha1.Size]byte // Unique identifier for this Notice
-	Type int             // Best guess as to the type of object this notice applies to
-	Text []byte          // The Notice text itself
+	Sha1   [sha1.Size]byte // Unique identifier for this Notice
+	Type   int             // Best guess as to the type of object this notice applies to
+	Text   []byte          // The Notice text itself, nil unless Status is Found
+	Status Status          // Whether copyright notices were found in the file
+	Magic  string          // The type file(1) gives the file, for an Unsupported one
+	// The codes of the languages the copyright notices in Text were
+	// found in, in the order they were first found, see tagger.Notice
+	Languages []string
+	// The highest confidence of the copyright notices in Text, 0 if it
+	// has none
+	Confidence float64
+	// Where Text was found in the file the notice was made from, in order;
+	// none for a notice that isn't from the text of the file
+	Spans []Span
+	// How long making the notice took, zero for one from a cache
+	Timing Timing
 
 	//
 	// XXX - Tad: Interface Violation: These are LicenseDB specific things, not Notice specific things
 	//
-	Count int
-	Files []string
-	Next  *Notice // next in the database bucket
+	Count       int
+	Occurrences []Occurrence // the files the notice is in, each once
+	Next        *Notice      // next in the database bucket
+}
+
+// What became of a file: whether copyright notices were found in it, and
+// if not why not
+type Status int
+
+const (
+	// Copyright notices were found in the file, and are in the Text
+	Found Status = iota
+	// The file is text, but has no copyright notice in it
+	NoNotice
+	// The file is compressed, binary or of an unknown type, and wasn't
+	// looked at, see Magic
+	Unsupported
+)
+
+var statusNames = []string{"found", "no notice", "unsupported"}
+
+func (status Status) String() string {
+	return statusNames[status]
+}
+
+// How long the steps of making a notice took
+type Timing struct {
+	Magic    time.Duration // telling the type of the file with file(1)
+	Comments time.Duration // finding the comments in it
+	Tagging  time.Duration // finding the copyright notices in it, and the text to report
+}
+
+// A part of a file: its first and last lines, counted from 1, and the
+// byte offsets of its start and end
+type Span struct {
+	StartLine int
+	EndLine   int
+	Start     int
+	End       int
+}
+
+// Returns the span of raw from start to end
+func newSpan(raw []byte, start int, end int) Span {
+	startLine := bytes.Count(raw[:start], []byte("\n")) + 1
+	endLine := startLine
+	if end > start {
+		endLine += bytes.Count(raw[start:end-1], []byte("\n"))
+	}
+	return Span{StartLine: startLine, EndLine: endLine, Start: start, End: end}
+}
+
+// Adds the span of raw from start to end to the spans, which it comes
+// after: to the last of them if it starts on the line after it or before
+func addSpan(spans []Span, raw []byte, start int, end int) []Span {
+	span := newSpan(raw, start, end)
+	if last := len(spans) - 1; last >= 0 && span.StartLine <= spans[last].EndLine+1 {
+		if span.End > spans[last].End {
+			spans[last].EndLine = span.EndLine
+			spans[last].End = span.End
+		}
+		return spans
+	}
+	return append(spans, span)
+}
+
+// "lines 3-5 (bytes 20-300)", or "line 3 (bytes 20-60)"
+func (span Span) String() string {
+	if span.StartLine == span.EndLine {
+		return fmt.Sprintf("line %d (bytes %d-%d)", span.StartLine, span.Start, span.End)
+	}
+	return fmt.Sprintf("lines %d-%d (bytes %d-%d)", span.StartLine, span.EndLine, span.Start, span.End)
+}
+
+// A file a notice is in, and where in it
+type Occurrence struct {
+	Path  string
+	Spans []Span
+}
+
+// "path:line", the line being the first of the notice in the file, or just
+// the path if the notice isn't from the text of the file
+func (occurrence Occurrence) String() string {
+	return location(occurrence.Path, occurrence.Spans)
+}
+
+func location(path string, spans []Span) string {
+	if len(spans) == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s:%d", path, spans[0].StartLine)
 }
 
 // Define the different comment styles as strings
 const cStyle string = "(/\\*([^*]|(\\*+([^*/])))*\\*+/)|(([ \\t]*//[^\r\n]*[\r\n])+)"
 const htmlStyle string = "(<!--([^-]|(-*([^->])|->))*-+->)"
-const pythonStyle string = "(([ \\t]*#[^\r\n]*[\r\n])+)|(\"\"\"([^\"]|(\"[^\"]|\"\"[^\"]))*\"\"+\")"
+const pythonStyle string = "(([ \\t]*#[^\r\n]*[\r\n])+)|([ \\t]*\"\"\"([^\"]|(\"[^\"]|\"\"[^\"]))*\"\"+\")"
 const shellStyle string = "(([ \\t]*#[^\r\n]*[\r\n])+)"
 const m4Style string = "(([ \\t]*#[^\r\n]*[\r\n])+)|(([ \\t]*dnl[^\r\n]*[\r\n])+)"
 const pascalStyle string = "(([ \\t]*//[^\r\n]*[\r\n])+)"
@@ -77,55 +187,101 @@ var rcomment = regexp.MustCompile(cStyle + "|" + htmlStyle + "|" + pythonStyle +
 
 const noNotice = "No copyright notice found"
 
-func mkNotice(path string, ltype int, ltext []byte, showNotice bool) (*Notice, error) {
+// Makes the notice of a file; with no text, one that says no copyright
+// notice was found in it
+func mkNotice(path string, ltype int, ltext []byte, languages []string, confidence float64, spans []Span, showNotice bool) (*Notice, error) {
+	status := Found
 	if ltext == nil {
-		ltext = []byte(noNotice + "\n")
+		status = NoNotice
 	}
 
 	notice := &Notice{
-		Text: ltext,
-		Type: ltype,
-		Sha1: sha1.Sum(ltext),
+		Text:       ltext,
+		Type:       ltype,
+		Status:     status,
+		Sha1:       sha1.Sum(ltext),
+		Languages:  languages,
+		Confidence: confidence,
+		Spans:      spans,
 	}
 
 	if showNotice {
-		log.Printf("[LICENSE %s] Signature %v\n", path, notice.Sha1)
+		log.Printf("[LICENSE %s] Signature %v\n", location(path, spans), notice.Sha1)
 	}
 
 	return notice, nil
 }
 
-func extractCopyrightNotices(path string, raw []byte, verbose bool, showNotice bool, copyrightTagger *tagger.Tagger) ([]byte, error) {
+// Makes the notice of a file that isn't looked at for its type, which
+// took magic to tell
+func mkUnsupported(path string, ltype int, m *filemagic.Magic, magic time.Duration, showNotice bool) (*Notice, error) {
+	if showNotice {
+		log.Printf("[LIC %s] Unsupported Filetype: %s\n", path, m)
+	}
+
+	return &Notice{Type: ltype, Status: Unsupported, Magic: m.String(), Timing: Timing{Magic: magic}}, nil
+}
+
+// Adds the languages of the notices that aren't in languages yet
+func addLanguages(languages []string, notices []tagger.Notice) []string {
+	for _, n := range notices {
+		found := false
+		for _, language := range languages {
+			if language == n.Language {
+				found = true
+				break
+			}
+		}
+		if !found {
+			languages = append(languages, n.Language)
+		}
+	}
+	return languages
+}
+
+// Returns the higher of confidence and those of the notices
+func maxConfidence(confidence float64, notices []tagger.Notice) float64 {
+	for _, n := range notices {
+		if n.Confidence > confidence {
+			confidence = n.Confidence
+		}
+	}
+	return confidence
+}
+
+func extractCopyrightNotices(path string, raw []byte, verbose bool, showNotice bool, copyrightTagger *tagger.Tagger) ([]byte, []string, float64, []Span, error) {
 	if showNotice {
 		log.Printf("[LIC %s]: found copyright outside of comments\n", path)
 	}
 
-	cindex := copyrightTagger.FindAllIndex(raw)
+	cindex := copyrightTagger.FindAll(raw)
 	if cindex == nil {
-		return nil, fmt.Errorf("%s: matched a copyright but couldn't find it", path)
+		return nil, nil, 0, nil, NewError(path, TaggerError, fmt.Errorf("matched a copyright but couldn't find it"))
 	}
 
 	var ltext []byte
+	var spans []Span
 	for i := 0; i < len(cindex); i++ {
-		start := cindex[i][0]
-		end := cindex[i][1]
+		start := cindex[i].Start
+		end := sentenceEnd(raw, cindex[i].End)
 
 		if showNotice {
-			log.Printf("[COPYRIGHT %s] %s\n", path, string(raw[start:end]))
+			log.Printf("[COPYRIGHT %s] %s\n", location(path, []Span{newSpan(raw, start, end)}), string(raw[start:end]))
 		}
 
 		ltext = append(ltext, raw[start:end]...)
 		ltext = append(ltext, '\n')
+		spans = addSpan(spans, raw, start, end)
 	}
 
-	return ltext, nil
+	return ltext, addLanguages(nil, cindex), maxConfidence(0, cindex), spans, nil
 }
 
 func skipFile(path string) (*filemagic.Magic, int, error) {
 	magic, err := filemagic.New(path)
 
 	if err != nil {
-		return nil, ERR, err
+		return nil, ERR, NewError(path, MagicError, err)
 	}
 
 	if magic.IsCompressed() {
@@ -146,52 +302,113 @@ func skipFile(path string) (*filemagic.Magic, int, error) {
 //
 // 1. If this is an unsupported filetype, return a notice to that effect, including identifying the type of file
 //
-// 2. Look for a copyright notice, if none was found, return a canonical "unknown copyright" notice.
+// 2. Look for a copyright notice, if none was found, return a notice to that effect.
 //
 // 3. If a copyright notice was found, but no comments were found, just include the copyright notice.
 //
-// 4. If a copyright notice was found, create the notice text by including all of the
-//    text from all comment blocks which have copyright notices.
+// 4. If a copyright notice was found, create the notice text by including, from all of
+//    the comment blocks which have copyright notices, as much of the comment as the
+//    granularity says: the copyright statements, their paragraphs and the license
+//    paragraph after each, or the whole comment.
 //
 // 5. As a last resort, if a copyright notice was found, and comments were found, but the copyright
 //    notice wasn't found in a comment, just include the copyright notice.
 //
-func NewNoticeFromFile(path string, verbose bool, showNotice bool, copyrightTagger *tagger.Tagger) (*Notice, error) {
+// The errors returned are *Error, of the kind of failure.
+//
+func NewNoticeFromFile(path string, verbose bool, showNotice bool, copyrightTagger *tagger.Tagger, granularity Granularity) (*Notice, error) {
+	lic, magic, err := CheckFile(path, verbose, showNotice)
+	if lic != nil || err != nil {
+		return lic, err
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, NewError(path, ReadError, err)
+	}
+
+	return NewNoticeFromBytes(path, raw, magic, verbose, showNotice, copyrightTagger, granularity)
+}
+
+//
+// The first half of NewNoticeFromFile, for callers that look the contents
+// of the file up (e.g. in a cache) first: tells the type of the file at
+// path by its magic.  A file of a type that isn't tagged has its notice
+// returned, one saying so; else there is no notice, and the time the
+// magic took is for NewNoticeFromBytes.
+//
+func CheckFile(path string, verbose bool, showNotice bool) (*Notice, time.Duration, error) {
 
 	if verbose {
 		log.Printf("[LIC] Process %s\n", path)
 	}
 
+	start := time.Now()
 	m, ltype, err := skipFile(path)
+	magic := time.Since(start)
 	if err != nil {
 		if m == nil {
-			return nil, err
+			return nil, magic, err
 		}
-		return mkNotice(path, ltype, append([]byte("Unsupported Filetype: "), m.Magic...), showNotice)
+		lic, err := mkUnsupported(path, ltype, m, magic, showNotice)
+		return lic, magic, err
 	}
+	return nil, magic, nil
+}
 
-	raw, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
+//
+// The second half of NewNoticeFromFile: the notice of raw, the contents
+// of the file at path that CheckFile found to be tagged in magic.
+//
+func NewNoticeFromBytes(path string, raw []byte, magic time.Duration, verbose bool, showNotice bool, copyrightTagger *tagger.Tagger, granularity Granularity) (*Notice, error) {
+	return newNotice(path, SRC, raw, magic, verbose, showNotice, copyrightTagger, granularity)
+}
+
+//
+// The notice of the contents of a file, the type of which took magic to
+// tell.  A panic in tagging is returned as a PanicError, and a notice the
+// tagger matches but can't find as a TaggerError along with the notice of
+// a file with no notice.
+//
+func newNotice(path string, ltype int, raw []byte, magic time.Duration, verbose bool, showNotice bool, copyrightTagger *tagger.Tagger, granularity Granularity) (lic *Notice, err error) {
+	start := time.Now()
+	var comments time.Duration
+	defer func() {
+		r := recover()
+		if r != nil {
+			if verbose {
+				log.Printf("[PANIC %s] %v\n%s", path, r, debug.Stack())
+			}
+			lic, err = nil, NewError(path, PanicError, fmt.Errorf("%v", r))
+		}
+		if lic != nil {
+			lic.Timing = Timing{Magic: magic, Comments: comments, Tagging: time.Since(start) - comments}
+		}
+	}()
 
 	// Check to see if any copyright notice exists in this file within or not inside of comments
 	if !copyrightTagger.Match(raw) {
 		if showNotice {
 			log.Printf("[LIC %s] %s\n", path, noNotice)
 		}
-		return mkNotice(path, ltype, nil, showNotice)
+		return mkNotice(path, ltype, nil, nil, 0, nil, showNotice)
 	}
 
+	commentStart := time.Now()
 	cindex := rcomment.FindAllIndex(raw, -1)
+	comments = time.Since(commentStart)
 	var ltext []byte
+	var languages []string
+	var confidence float64
+	var spans []Span
 
 	if cindex == nil {
-		ltext, err = extractCopyrightNotices(path, raw, verbose, showNotice, copyrightTagger)
+		ltext, languages, confidence, spans, err = extractCopyrightNotices(path, raw, verbose, showNotice, copyrightTagger)
 		if err != nil {
-			return nil, err
+			lic, _ = mkNotice(path, ltype, nil, nil, 0, nil, showNotice)
+			return lic, err
 		}
-		return mkNotice(path, ltype, ltext, showNotice)
+		return mkNotice(path, ltype, ltext, languages, confidence, spans, showNotice)
 	}
 
 	for i := 0; i < len(cindex); i++ {
@@ -199,25 +416,32 @@ func NewNoticeFromFile(path string, verbose bool, showNotice bool, copyrightTagg
 		end := cindex[i][1]
 
 		// If the comment does contain something I want I take it if not I skip and go back to the top
-		if !copyrightTagger.Match(raw[start:end]) {
+		text, notices, ranges := granularity.noticeText(raw[start:end], copyrightTagger)
+		if len(notices) == 0 {
 			continue
 		}
+		languages = addLanguages(languages, notices)
+		confidence = maxConfidence(confidence, notices)
+		for _, r := range ranges {
+			spans = addSpan(spans, raw, start+r[0], start+r[1])
+		}
 
 		if showNotice {
-			log.Printf("[LICENSE %s] %s\n", path, string(raw[start:end]))
+			log.Printf("[LICENSE %s] %s\n", location(path, spans), string(text))
 		}
 
-		ltext = append(ltext, raw[start:end]...)
+		ltext = append(ltext, text...)
 		ltext = append(ltext, '\n')
 	}
 
 	if ltext == nil {
-		ltext, err = extractCopyrightNotices(path, raw, verbose, showNotice, copyrightTagger)
+		ltext, languages, confidence, spans, err = extractCopyrightNotices(path, raw, verbose, showNotice, copyrightTagger)
 		if err != nil {
-			return nil, err
+			lic, _ = mkNotice(path, ltype, nil, nil, 0, nil, showNotice)
+			return lic, err
 		}
-		return mkNotice(path, ltype, ltext, showNotice)
+		return mkNotice(path, ltype, ltext, languages, confidence, spans, showNotice)
 	}
 
-	return mkNotice(path, ltype, ltext, showNotice)
+	return mkNotice(path, ltype, ltext, languages, confidence, spans, showNotice)
 }
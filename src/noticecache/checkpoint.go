@@ -0,0 +1,188 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package noticecache
+
+import (
+	"encoding/gob"
+	"log"
+	"notice"
+	"os"
+	"sync"
+	"time"
+)
+
+//
+// Checkpoint records the notices of the files a scan has done by path, so
+// that an interrupted scan can be resumed without reading them again.  A
+// file is only taken as done if its size and modification time are the
+// same as when it was.
+//
+type Checkpoint struct {
+	Version string // as the Cache Version
+
+	mu       sync.Mutex
+	previous map[string]checkpointFile // done by the scan resumed
+	files    map[string]checkpointFile // done by this scan
+}
+
+type checkpointFile struct {
+	Size    int64
+	ModTime time.Time
+	Entry   Entry
+}
+
+// on disk format of a saved Checkpoint
+type checkpointSave struct {
+	Format  int
+	Version string
+	Files   map[string]checkpointFile
+}
+
+func NewCheckpoint(version string) *Checkpoint {
+	return &Checkpoint{
+		Version:  version,
+		previous: make(map[string]checkpointFile),
+		files:    make(map[string]checkpointFile),
+	}
+}
+
+//
+// Returns a new Notice for path if the scan resumed did it and it hasn't
+// changed since, else nil
+//
+func (c *Checkpoint) Get(path string, info os.FileInfo) *notice.Notice {
+	c.mu.Lock()
+	done, ok := c.previous[path]
+	c.mu.Unlock()
+
+	if !ok || done.Size != info.Size() || !done.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	return done.Entry.notice()
+}
+
+// Records that path is done, with notice n
+func (c *Checkpoint) Put(path string, info os.FileInfo, n *notice.Notice) {
+	c.mu.Lock()
+	c.files[path] = checkpointFile{Size: info.Size(), ModTime: info.ModTime(), Entry: newEntry(n)}
+	c.mu.Unlock()
+}
+
+// Returns how many files are done, those of the scan resumed included,
+// and how many of them this scan did
+func (c *Checkpoint) Len() (total int, done int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total = len(c.previous)
+	for path := range c.files {
+		_, ok := c.previous[path]
+		if !ok {
+			total++
+		}
+	}
+	return total, len(c.files)
+}
+
+//
+// Loads a checkpoint saved by Save to resume the scan of.  A missing file,
+// or one made by a different tool version or tagger model, yields a
+// checkpoint with nothing done.
+//
+func LoadCheckpoint(path string, version string, verbose bool) (*Checkpoint, error) {
+	c := NewCheckpoint(version)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var cs checkpointSave
+	err = gob.NewDecoder(f).Decode(&cs)
+	if err != nil {
+		return nil, err
+	}
+
+	if cs.Format != cacheFormat || cs.Version != version {
+		if verbose {
+			log.Printf("[CHECKPOINT] %s: made by %q, ignoring\n", path, cs.Version)
+		}
+		return c, nil
+	}
+
+	if cs.Files != nil {
+		c.previous = cs.Files
+	}
+
+	if verbose {
+		log.Printf("[CHECKPOINT] %s: resuming from %d files\n", path, len(c.previous))
+	}
+
+	return c, nil
+}
+
+//
+// Writes the files done to path, those of the scan resumed included,
+// going through a temporary file as Cache.Save does.  A file changed since
+// the scan resumed did it is saved as this scan found it.
+//
+func (c *Checkpoint) Save(path string) error {
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	files := make(map[string]checkpointFile, len(c.previous)+len(c.files))
+	for path, done := range c.previous {
+		files[path] = done
+	}
+	for path, done := range c.files {
+		files[path] = done
+	}
+	c.mu.Unlock()
+
+	err = gob.NewEncoder(f).Encode(&checkpointSave{Format: cacheFormat, Version: c.Version, Files: files})
+
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
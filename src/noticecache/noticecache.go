@@ -0,0 +1,209 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package noticecache
+
+import (
+	"crypto/sha1"
+	"encoding/gob"
+	"log"
+	"notice"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+type Key [sha1.Size]byte
+
+//
+// Entry holds just enough of a Notice to recreate it.  The LicenseDB
+// specific parts of a Notice (Count, Occurrences, Next) are never cached.
+//
+type Entry struct {
+	Type       int
+	Text       []byte
+	Status     notice.Status
+	Magic      string
+	Languages  []string
+	Confidence float64
+	Spans      []notice.Span
+}
+
+//
+// Cache maps the SHA1 of a file's contents to the Notice extracted from
+// it, so that identical files (config.guess, ltmain.sh, install-sh, ...)
+// only go through filemagic and the tagger once.
+//
+type Cache struct {
+	Version string // tool version and tagger model the entries were made with
+
+	mu      sync.RWMutex
+	entries map[Key]Entry
+
+	Hits   uint64
+	Misses uint64
+}
+
+// on disk format of a persisted Cache
+type cacheFile struct {
+	Format  int
+	Version string
+	Entries map[Key]Entry
+}
+
+// The Format of the cache files written, changed with the fields of Entry
+// so that entries missing the new fields aren't used
+const cacheFormat = 2
+
+func New(version string) *Cache {
+	return &Cache{
+		Version: version,
+		entries: make(map[Key]Entry),
+	}
+}
+
+func Sum(raw []byte) Key {
+	return Key(sha1.Sum(raw))
+}
+
+//
+// Returns a new Notice for the given content hash, or nil on a miss.  A
+// fresh Notice is made each time because the LicenseDB links and counts
+// the notices it is given.
+//
+func (c *Cache) Get(k Key) *notice.Notice {
+	c.mu.RLock()
+	e, ok := c.entries[k]
+	c.mu.RUnlock()
+
+	if !ok {
+		atomic.AddUint64(&c.Misses, 1)
+		return nil
+	}
+	atomic.AddUint64(&c.Hits, 1)
+
+	return e.notice()
+}
+
+func (c *Cache) Put(k Key, n *notice.Notice) {
+	c.mu.Lock()
+	c.entries[k] = newEntry(n)
+	c.mu.Unlock()
+}
+
+func newEntry(n *notice.Notice) Entry {
+	return Entry{Type: n.Type, Text: n.Text, Status: n.Status, Magic: n.Magic, Languages: n.Languages,
+		Confidence: n.Confidence, Spans: n.Spans}
+}
+
+func (e Entry) notice() *notice.Notice {
+	return &notice.Notice{
+		Sha1:       sha1.Sum(e.Text),
+		Type:       e.Type,
+		Text:       e.Text,
+		Status:     e.Status,
+		Magic:      e.Magic,
+		Languages:  e.Languages,
+		Confidence: e.Confidence,
+		Spans:      e.Spans,
+	}
+}
+
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.entries)
+}
+
+//
+// Loads a cache previously written by Save.  A missing file, or one made
+// by a different tool version or tagger model, yields an empty cache.
+//
+func Load(path string, version string, verbose bool) (*Cache, error) {
+	c := New(version)
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var cf cacheFile
+	err = gob.NewDecoder(f).Decode(&cf)
+	if err != nil {
+		return nil, err
+	}
+
+	if cf.Format != cacheFormat || cf.Version != version {
+		if verbose {
+			log.Printf("[CACHE] %s: made by %q, ignoring\n", path, cf.Version)
+		}
+		return c, nil
+	}
+
+	if cf.Entries != nil {
+		c.entries = cf.Entries
+	}
+
+	if verbose {
+		log.Printf("[CACHE] %s: loaded %d entries\n", path, len(c.entries))
+	}
+
+	return c, nil
+}
+
+//
+// Writes the cache to path, going through a temporary file so that an
+// interrupted save doesn't clobber the previous cache.
+//
+func (c *Cache) Save(path string) error {
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	err = gob.NewEncoder(f).Encode(&cacheFile{Format: cacheFormat, Version: c.Version, Entries: c.entries})
+	c.mu.RUnlock()
+
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
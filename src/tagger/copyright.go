@@ -35,7 +35,10 @@ POSSIBILITY OF SUCH DAMAGE.
 package tagger
 
 import (
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // tri structure defined in main tagger.go
@@ -45,6 +48,9 @@ import (
 //	pos   string
 //}
 
+// The states of the DFAs.  The number compression DFA of tagger.go uses
+// START, INTERM, REJECT and ACCEPT; the copyright DFA is now read from a
+// grammar, see grammar.go, whose default has states of the same names.
 const (
 	START   int = iota // start state
 	LPAREN             // left parenthesis
@@ -65,467 +71,537 @@ const (
 	INTERM             // Intermidiate state
 )
 
-// Given a string this will return whethe a copyright notice
-// of that string if it exists, if not the empty string is returned
-// The string must be tagged and propperly delimited
-// else will just tag
-// USING SHIFTING WINDOW STRATEGY
+// Given a string this will return whether it holds a copyright notice,
+// one that Extract would return and FindAllIndex would find.  The
+// scanning stops at the first notice, so a sentence after it is never
+// tagged.
+//
+// Only the windows of text around the hits of the Prefilter are looked at.
 func (copyrightTagger *Tagger) Match(inBytes []byte) bool {
-	var curByte = 0
-	var lastCheckedByte = 0
-	var currWords int // the amount of words in the notice
-	var taggedSent []TaggedWord
-
-	if len(inBytes) < 15 {
+	found := false
+	copyrightTagger.scanNotices(inBytes, false, func(notice noticeSpan) bool {
+		found = true
 		return false
+	})
+	return found
+}
+
+// The grammar compiled to a table of the next state by state and input,
+// the input being the word, one of the words of the grammar or any other,
+// and its tag in the tag set of the model.  A tag not in the tag set,
+// which no model tags with, is looked up in the grammar itself.
+type noticeTable struct {
+	grammar *NoticeGrammar
+	tags    *TagSet
+	// the words of the inputs of the grammar, in lower case
+	words []string
+	width int
+	next  []int
+
+	initial int
+	accept  int
+	reject  int
+}
+
+func compileNoticeDFA(grammar *NoticeGrammar, tags *TagSet) *noticeTable {
+	table := &noticeTable{grammar: grammar, tags: tags,
+		initial: grammar.initial, accept: grammar.accept, reject: grammar.reject}
+	for _, input := range grammar.inputs {
+		word := strings.ToLower(input.word)
+		if word != "" && indexOfWord(table.words, word) < 0 {
+			table.words = append(table.words, word)
+		}
 	}
 
-	for lastCheckedByte < len(inBytes) {
-		// this is shifting the window based on a period followed by space
-		for curByte < len(inBytes) {
-			if inBytes[curByte] == byte('.') {
-				if curByte+1 < len(inBytes) && inBytes[curByte+1] == byte(' ') {
-					curByte++
-					break
-				}
+	n := tags.Len()
+	table.width = (len(table.words) + 1) * n
+	table.next = make([]int, len(grammar.states)*table.width)
+	for state := range grammar.states {
+		row := table.next[state*table.width : (state+1)*table.width]
+		// the last word is any other word
+		for word := 0; word <= len(table.words); word++ {
+			example := ""
+			if word < len(table.words) {
+				example = table.words[word]
+			}
+			for tag := 0; tag < n; tag++ {
+				row[word*n+tag] = grammar.next[state][grammar.input(example, tags.Name(tag))]
 			}
-			curByte++
 		}
+	}
+	return table
+}
 
-		currWords = 0
-		// create array of tagged words
-		taggedSent = copyrightTagger.TagBytes(inBytes[lastCheckedByte:curByte])
+func indexOfWord(words []string, word string) int {
+	for i := range words {
+		if strings.EqualFold(words[i], word) {
+			return i
+		}
+	}
+	return -1
+}
 
-		currentState := REJECT
-		var potentialNotice []TaggedWord = make([]TaggedWord, 0)
-		var extractedNotice []TaggedWord = make([]TaggedWord, 0)
-		for _, taggedWord := range taggedSent {
+// Returns the state the DFA goes to from state on the word
+func (table *noticeTable) step(state int, taggedWord TaggedWord) int {
+	// a keyword of another language is read as the English one
+	text := taggedWord.word
+	if taggedWord.keyword != "" {
+		text = taggedWord.keyword
+	}
 
-			// Is what I have good enough to add to the extracted Notices
-			if currentState == ACCEPT {
-				currWords = 0
-				extractedNotice = append(extractedNotice, potentialNotice...)
-				potentialNotice = nil
-			}
-			// Transition to the next state given current 'input'
-			if strings.ToLower(taggedWord.word) == "copyright" || strings.ToLower(taggedWord.word) == "c" {
-				currentState = copyrightTagger.CopyrightDFA[Tri{currentState, strings.ToLower(taggedWord.word), taggedWord.tag}]
-			} else if strings.Contains(taggedWord.word, "©") {
-				currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "©", "sym"}]
-			} else if strings.Contains(copyrightTagger.CopyrightSyms, taggedWord.tag) {
-				currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "X", taggedWord.tag}]
-			} else {
-				currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "X", "X"}]
-			}
-			// Because of multiple notices right after the other here's a check...
-			if currentState == START || currentState == LPAREN || currentState == CSYM {
-				currWords = 1
-
-				if len(potentialNotice) > 3 { // Does it seem like something useful has been captured
-					extractedNotice = append(extractedNotice, potentialNotice...)
-					potentialNotice = nil
-					potentialNotice = append(potentialNotice, taggedWord)
-				} else {
-					potentialNotice = nil
-					potentialNotice = append(potentialNotice, taggedWord)
-				}
-			} else if currentState != REJECT && currentState != ACCEPT {
-				currWords += 1
-				potentialNotice = append(potentialNotice, taggedWord)
-			} else if currentState == REJECT {
-				currWords = 0
-				potentialNotice = nil
-			}
+	tag, ok := table.tags.Index(taggedWord.tag)
+	if !ok {
+		return table.grammar.next[state][table.grammar.input(text, taggedWord.tag)]
+	}
+
+	word := indexOfWord(table.words, grammarWord(text))
+	if word < 0 {
+		word = len(table.words)
+	}
+	return table.next[state*table.width+word*table.tags.Len()+tag]
+}
+
+// whether a notice begins with the word that leads to the state
+func (table *noticeTable) begins(state int) bool {
+	return table.grammar.states[state].begin
+}
 
-			// This is for optimization where all I care about yes or no
-			if currWords > 3 {
-				return true
+// whether a notice the text ends in while in the state is kept
+func (table *noticeTable) final(state int) bool {
+	return table.grammar.states[state].final
+}
+
+// whether a notice begun in the state is only kept if it is accepted
+func (table *noticeTable) anchored(state int) bool {
+	return table.grammar.states[state].anchored
+}
+
+// Makes the tagger find copyright notices by the grammar, compiling it
+// to the DFA once here.  The DFA reads tags by their names, so it needn't
+// be compiled again for another Model.
+func (copyrightTagger *Tagger) SetGrammar(grammar *NoticeGrammar) {
+	copyrightTagger.grammar = grammar
+	copyrightTagger.noticeTable = compileNoticeDFA(grammar, copyrightTagger.Model.Tags())
+}
+
+// Returns the grammar the tagger finds copyright notices by
+func (copyrightTagger *Tagger) Grammar() *NoticeGrammar {
+	return copyrightTagger.grammar
+}
+
+// Identifies all that the notices the tagger finds depend on: the model
+// and what it was trained from, the lexicon, the grammar, the languages,
+// the prefilter and the threshold; for caches of what was found with it
+func (copyrightTagger *Tagger) NoticeSignature() string {
+	return strings.Join([]string{
+		copyrightTagger.Signature,
+		copyrightTagger.Lexicon.Signature(),
+		copyrightTagger.grammar.Signature(),
+		copyrightTagger.Languages.Signature(),
+		copyrightTagger.Prefilter.Signature(),
+		strconv.FormatFloat(copyrightTagger.Threshold, 'g', -1, 64),
+	}, "/")
+}
+
+// Given a string this will return the copyright notices
+// of that string if they exist, if not the empty string is returned
+// The words of the notices are joined by single spaces
+// Only the windows of text around the hits of the Prefilter are looked at.
+func (copyrightTagger *Tagger) Extract(inBytes []byte) string {
+	var extractedNotice []TaggedWord
+	copyrightTagger.scanNotices(inBytes, false, func(notice noticeSpan) bool {
+		extractedNotice = append(extractedNotice, notice.words...)
+		return true
+	})
+
+	if len(extractedNotice) < 1 {
+		return ""
+	}
+	return toString(extractedNotice)
+}
+
+// similar to the regex findAllIndex, will return the byte offsets of
+// the notices: from the start of the first word of each to the end of
+// its last word
+// Only the windows of text around the hits of the Prefilter are looked at.
+func (copyrightTagger *Tagger) FindAllIndex(inBytes []byte) [][]int {
+	//Return array of indicies
+	var indicies = make([][]int, 0)
+	copyrightTagger.scanNotices(inBytes, false, func(notice noticeSpan) bool {
+		indicies = append(indicies, []int{notice.start, notice.end})
+		return true
+	})
+	return indicies
+}
+
+// A copyright notice found by FindAll: the byte offsets of the text it
+// spans, as FindAllIndex returns them, and the code of the language it is
+// in, English or one of the Languages of the tagger
+type Notice struct {
+	Start    int
+	End      int
+	Language string
+	// how sure the tagger is that it is a copyright notice, from 0 to 1,
+	// see Tagger.Threshold
+	Confidence float64
+}
+
+// Like FindAllIndex, but returns the language and the confidence of each
+// notice too
+// Only the windows of text around the hits of the Prefilter are looked at.
+func (copyrightTagger *Tagger) FindAll(inBytes []byte) []Notice {
+	var notices []Notice
+	copyrightTagger.scanNotices(inBytes, true, func(notice noticeSpan) bool {
+		notices = append(notices, Notice{Start: notice.start, End: notice.end, Language: notice.language(),
+			Confidence: notice.confidence})
+		return true
+	})
+	return notices
+}
+
+// A copyright notice found in a text: its tagged words, with byteStart
+// the offset in the text, and the bytes of the text they span.  The
+// confidence is 0 when it isn't worked out, see scanNotices.
+type noticeSpan struct {
+	words      []TaggedWord
+	start      int
+	end        int
+	confidence float64
+}
+
+// The language of the notice, that of the first keyword of another
+// language in it, else English
+func (notice noticeSpan) language() string {
+	for _, taggedWord := range notice.words {
+		if taggedWord.lang != "" {
+			return taggedWord.lang
+		}
+	}
+	return English
+}
+
+// Texts this short hold no copyright notice: "© HP 1999" is as short as
+// one gets
+const minNoticeLen = 10
+
+// Scans the text for copyright notices, calling found with each in the
+// order they appear until it returns false.  Match, Extract and
+// FindAllIndex are all this, so that what one of them finds the others
+// do too.
+//
+// The text is tagged a sentence at a time, the sentences running to a
+// period followed by a space but for the period of an abbreviation such
+// as "Inc.", while the DFA reads the words of the sentences one after the
+// other.  The words of a notice are those from the word that begins it
+// (see NoticeGrammar) up to:
+//
+//   - the word an accept state of the DFA is left on; the notice is kept
+//   - the word that begins another notice or a reject state is entered
+//     on; the notice is kept if it has more than 3 words
+//   - the end of the text; the notice is kept if it ends in the accept
+//     state, or in a final state, or has more than 3 words
+//
+// A notice begun in an anchored state is only kept by the accept state.
+//
+// The notice spans the text from the start of its first word to the end
+// of its last word.  Its confidence is only worked out if asked for, or
+// there is a Threshold.
+func (copyrightTagger *Tagger) scanNotices(inBytes []byte, confidence bool, found func(notice noticeSpan) bool) {
+	copyrightTagger.traceNotices(inBytes, nil, confidence, found)
+}
+
+// scanNotices, recording what it does in the explanation if it isn't nil
+func (copyrightTagger *Tagger) traceNotices(inBytes []byte, trace *Explanation, confidence bool, found func(notice noticeSpan) bool) {
+	if len(inBytes) < minNoticeLen {
+		return
+	}
+
+	// the probabilities of the tags are only worked out for the
+	// confidence, which Explain gives too
+	confidence = confidence || trace != nil || copyrightTagger.Threshold > 0
+	scanner := noticeScanner{dfa: copyrightTagger.noticeTable, threshold: copyrightTagger.Threshold,
+		confidence: confidence, found: found, trace: trace}
+	for _, window := range copyrightTagger.Prefilter.windows(inBytes) {
+		trace.window(window[0], window[1])
+		scanner.text = inBytes[:window[1]]
+		scanner.state = scanner.dfa.initial
+		scanner.words = nil
+		scanner.anchored = false
+		scanner.reachedFinal = false
+		scanner.finalWords = 0
+
+		for start := window[0]; start < window[1] && !scanner.stopped; {
+			end := copyrightTagger.sentenceEnd(inBytes[:window[1]], start)
+			trace.sentence(start, end)
+			for _, taggedWord := range copyrightTagger.tagBytes(inBytes[start:end], confidence) {
+				// the tokenizer ends the text in an empty word
+				if taggedWord.word == "" {
+					continue
+				}
+				taggedWord.byteStart += start
+				scanner.read(taggedWord)
+				if scanner.stopped {
+					return
+				}
 			}
+			start = end
 		}
+
 		// Do a final check to see if I might have a notice as the very last part of the string
-		// Be a little more vauge here to be safe
-		if currentState == ACCEPT || currentState == CD || currentState == NP {
-			extractedNotice = append(extractedNotice, potentialNotice...)
+		if scanner.state == scanner.dfa.accept {
+			scanner.emit(window[1], pathAccepted)
+		} else if scanner.keeps(scanner.dfa.final(scanner.state)) {
+			scanner.emit(window[1], scanner.path())
+		} else {
+			scanner.drop(window[1])
 		}
-		// Return the extracted notice or the not found notice as a string
-		if len(extractedNotice) > 3 {
-			return true
+		if scanner.stopped {
+			return
 		}
+	}
+}
 
-		lastCheckedByte = curByte
+// Returns the end of the sentence of the text that starts at start: after
+// the next period followed by a space that doesn't end an abbreviation,
+// else the end of the text
+func (copyrightTagger *Tagger) sentenceEnd(text []byte, start int) int {
+	for curByte := start; curByte < len(text); curByte++ {
+		if text[curByte] == byte('.') && curByte+1 < len(text) && text[curByte+1] == byte(' ') &&
+			!copyrightTagger.Lexicon.endsWithCompound(text[start:curByte+1]) {
+			return curByte + 1
+		}
 	}
-	return false // no copyright notice detected
-}
-
-// creates the DFA and symbol "array" needed to test the transitions
-// for when a copyright notice can be found or noticed
-func mkNoticeDFA() (string, map[Tri]int) {
-	symbols := "(,),cd,np,dt,in,--,.,sym,cc"
-	dfa := make(map[Tri]int)
-
-	// possible copyright symbols
-	// ©, â©, Å©
-	dfa[Tri{START, "copyright", "nn"}] = START
-	dfa[Tri{LPAREN, "copyright", "nn"}] = START
-	dfa[Tri{CCHAR, "copyright", "nn"}] = START
-	dfa[Tri{RPAREN, "copyright", "nn"}] = START
-	dfa[Tri{NP, "copyright", "nn"}] = START
-	dfa[Tri{COMMA, "copyright", "nn"}] = START
-	dfa[Tri{CD, "copyright", "nn"}] = START
-	dfa[Tri{DASH, "copyright", "nn"}] = START
-	dfa[Tri{IN, "copyright", "nn"}] = START
-	dfa[Tri{DT, "copyright", "nn"}] = START
-	dfa[Tri{ACCEPT, "copyright", "nn"}] = START
-	dfa[Tri{REJECT, "copyright", "nn"}] = START
-	dfa[Tri{SYM, "copyright", "nn"}] = START
-	dfa[Tri{OTHER, "copyright", "nn"}] = START
-	dfa[Tri{CSYM, "copyright", "nn"}] = START
-	dfa[Tri{LPARENC, "copyright", "nn"}] = START
-
-	dfa[Tri{START, "c", "nn"}] = REJECT
-	dfa[Tri{LPAREN, "c", "nn"}] = CCHAR
-	dfa[Tri{CCHAR, "c", "nn"}] = REJECT
-	dfa[Tri{RPAREN, "c", "nn"}] = REJECT
-	dfa[Tri{NP, "c", "nn"}] = REJECT
-	dfa[Tri{COMMA, "c", "nn"}] = REJECT
-	dfa[Tri{CD, "c", "nn"}] = REJECT
-	dfa[Tri{DASH, "c", "nn"}] = REJECT
-	dfa[Tri{IN, "c", "nn"}] = REJECT
-	dfa[Tri{DT, "c", "nn"}] = REJECT
-	dfa[Tri{ACCEPT, "c", "nn"}] = REJECT
-	dfa[Tri{REJECT, "c", "nn"}] = REJECT
-	dfa[Tri{SYM, "c", "nn"}] = REJECT
-	dfa[Tri{OTHER, "c", "nn"}] = REJECT
-	dfa[Tri{CSYM, "c", "nn"}] = REJECT
-	dfa[Tri{LPARENC, "c", "nn"}] = CCHAR
-
-	dfa[Tri{START, "©", "sym"}] = CSYM
-	dfa[Tri{LPAREN, "©", "sym"}] = CSYM
-	dfa[Tri{CCHAR, "©", "sym"}] = CSYM
-	dfa[Tri{RPAREN, "©", "sym"}] = CSYM
-	dfa[Tri{NP, "©", "sym"}] = CSYM
-	dfa[Tri{COMMA, "©", "sym"}] = CSYM
-	dfa[Tri{CD, "©", "sym"}] = CSYM
-	dfa[Tri{DASH, "©", "sym"}] = CSYM
-	dfa[Tri{IN, "©", "sym"}] = CSYM
-	dfa[Tri{DT, "©", "sym"}] = CSYM
-	dfa[Tri{ACCEPT, "©", "sym"}] = ACCEPT
-	dfa[Tri{REJECT, "©", "sym"}] = CSYM
-	dfa[Tri{SYM, "©", "sym"}] = CSYM
-	dfa[Tri{OTHER, "©", "sym"}] = CSYM
-	dfa[Tri{CSYM, "©", "sym"}] = CSYM
-	dfa[Tri{LPARENC, "©", "sym"}] = CSYM
-
-	dfa[Tri{START, "X", "("}] = LPARENC
-	dfa[Tri{LPAREN, "X", "("}] = REJECT
-	dfa[Tri{CCHAR, "X", "("}] = REJECT
-	dfa[Tri{RPAREN, "X", "("}] = REJECT
-	dfa[Tri{NP, "X", "("}] = REJECT
-	dfa[Tri{COMMA, "X", "("}] = REJECT
-	dfa[Tri{CD, "X", "("}] = REJECT
-	dfa[Tri{DASH, "X", "("}] = REJECT
-	dfa[Tri{IN, "X", "("}] = REJECT
-	dfa[Tri{DT, "X", "("}] = REJECT
-	dfa[Tri{ACCEPT, "X", "("}] = REJECT
-	dfa[Tri{REJECT, "X", "("}] = LPAREN
-	dfa[Tri{SYM, "X", "("}] = REJECT
-	dfa[Tri{OTHER, "X", "("}] = REJECT
-	dfa[Tri{CSYM, "X", "("}] = LPAREN
-	dfa[Tri{LPARENC, "X", "("}] = REJECT
-
-	dfa[Tri{START, "X", ")"}] = REJECT
-	dfa[Tri{LPAREN, "X", ")"}] = REJECT
-	dfa[Tri{CCHAR, "X", ")"}] = RPAREN
-	dfa[Tri{RPAREN, "X", ")"}] = REJECT
-	dfa[Tri{NP, "X", ")"}] = REJECT
-	dfa[Tri{COMMA, "X", ")"}] = REJECT
-	dfa[Tri{CD, "X", ")"}] = REJECT
-	dfa[Tri{DASH, "X", ")"}] = REJECT
-	dfa[Tri{IN, "X", ")"}] = REJECT
-	dfa[Tri{DT, "X", ")"}] = REJECT
-	dfa[Tri{ACCEPT, "X", ")"}] = REJECT
-	dfa[Tri{REJECT, "X", ")"}] = REJECT
-	dfa[Tri{SYM, "X", ")"}] = RPAREN
-	dfa[Tri{OTHER, "X", ")"}] = REJECT
-	dfa[Tri{CSYM, "X", ")"}] = RPAREN
-	dfa[Tri{LPARENC, "X", ")"}] = REJECT
-
-	dfa[Tri{START, "X", "cd"}] = CD
-	dfa[Tri{LPAREN, "X", "cd"}] = REJECT
-	dfa[Tri{CCHAR, "X", "cd"}] = REJECT
-	dfa[Tri{RPAREN, "X", "cd"}] = CD
-	dfa[Tri{NP, "X", "cd"}] = CD
-	dfa[Tri{COMMA, "X", "cd"}] = CD
-	dfa[Tri{CD, "X", "cd"}] = CD
-	dfa[Tri{DASH, "X", "cd"}] = CD
-	dfa[Tri{IN, "X", "cd"}] = REJECT
-	dfa[Tri{DT, "X", "cd"}] = REJECT
-	dfa[Tri{ACCEPT, "X", "cd"}] = ACCEPT
-	dfa[Tri{REJECT, "X", "cd"}] = REJECT
-	dfa[Tri{SYM, "X", "cd"}] = CD
-	dfa[Tri{OTHER, "X", "cd"}] = CD
-	dfa[Tri{CSYM, "X", "cd"}] = CD
-	dfa[Tri{LPARENC, "X", "cd"}] = REJECT
-
-	dfa[Tri{START, "X", "np"}] = NP
-	dfa[Tri{LPAREN, "X", "np"}] = REJECT
-	dfa[Tri{CCHAR, "X", "np"}] = REJECT
-	dfa[Tri{RPAREN, "X", "np"}] = NP
-	dfa[Tri{NP, "X", "np"}] = NP
-	dfa[Tri{COMMA, "X", "np"}] = NP
-	dfa[Tri{CD, "X", "np"}] = NP
-	dfa[Tri{DASH, "X", "np"}] = NP
-	dfa[Tri{IN, "X", "np"}] = NP
-	dfa[Tri{DT, "X", "np"}] = NP
-	dfa[Tri{ACCEPT, "X", "np"}] = ACCEPT
-	dfa[Tri{REJECT, "X", "np"}] = REJECT
-	dfa[Tri{SYM, "X", "np"}] = NP
-	dfa[Tri{OTHER, "X", "np"}] = NP
-	dfa[Tri{CSYM, "X", "np"}] = NP
-	dfa[Tri{LPARENC, "X", "np"}] = REJECT
-
-	dfa[Tri{START, "X", "dt"}] = DT
-	dfa[Tri{LPAREN, "X", "dt"}] = REJECT
-	dfa[Tri{CCHAR, "X", "dt"}] = REJECT
-	dfa[Tri{RPAREN, "X", "dt"}] = DT
-	dfa[Tri{NP, "X", "dt"}] = DT
-	dfa[Tri{COMMA, "X", "dt"}] = DT
-	dfa[Tri{CD, "X", "dt"}] = DT
-	dfa[Tri{DASH, "X", "dt"}] = DT
-	dfa[Tri{IN, "X", "dt"}] = DT
-	dfa[Tri{DT, "X", "dt"}] = REJECT
-	dfa[Tri{ACCEPT, "X", "dt"}] = REJECT
-	dfa[Tri{REJECT, "X", "dt"}] = REJECT
-	dfa[Tri{SYM, "X", "dt"}] = REJECT
-	dfa[Tri{OTHER, "X", "dt"}] = DT
-	dfa[Tri{CSYM, "X", "dt"}] = DT
-	dfa[Tri{LPARENC, "X", "dt"}] = REJECT
-
-	dfa[Tri{START, "X", "in"}] = REJECT
-	dfa[Tri{LPAREN, "X", "in"}] = REJECT
-	dfa[Tri{CCHAR, "X", "in"}] = REJECT
-	dfa[Tri{RPAREN, "X", "in"}] = REJECT
-	dfa[Tri{NP, "X", "in"}] = IN
-	dfa[Tri{COMMA, "X", "in"}] = REJECT
-	dfa[Tri{CD, "X", "in"}] = IN
-	dfa[Tri{DASH, "X", "in"}] = REJECT
-	dfa[Tri{IN, "X", "in"}] = REJECT
-	dfa[Tri{DT, "X", "in"}] = REJECT
-	dfa[Tri{ACCEPT, "X", "in"}] = REJECT
-	dfa[Tri{REJECT, "X", "in"}] = REJECT
-	dfa[Tri{SYM, "X", "in"}] = REJECT
-	dfa[Tri{OTHER, "X", "in"}] = IN
-	dfa[Tri{CSYM, "X", "in"}] = IN
-	dfa[Tri{LPARENC, "X", "in"}] = REJECT
-
-	dfa[Tri{START, "X", "--"}] = REJECT
-	dfa[Tri{LPAREN, "X", "--"}] = REJECT
-	dfa[Tri{CCHAR, "X", "--"}] = REJECT
-	dfa[Tri{RPAREN, "X", "--"}] = REJECT
-	dfa[Tri{NP, "X", "--"}] = DASH
-	dfa[Tri{COMMA, "X", "--"}] = REJECT
-	dfa[Tri{CD, "X", "--"}] = DASH
-	dfa[Tri{DASH, "X", "--"}] = REJECT
-	dfa[Tri{IN, "X", "--"}] = REJECT
-	dfa[Tri{DT, "X", "--"}] = REJECT
-	dfa[Tri{ACCEPT, "X", "--"}] = REJECT
-	dfa[Tri{REJECT, "X", "--"}] = REJECT
-	dfa[Tri{SYM, "X", "--"}] = DASH
-	dfa[Tri{OTHER, "X", "--"}] = REJECT
-	dfa[Tri{CSYM, "X", "--"}] = DASH
-	dfa[Tri{LPARENC, "X", "--"}] = REJECT
-
-	dfa[Tri{START, "X", ","}] = REJECT
-	dfa[Tri{LPAREN, "X", ","}] = REJECT
-	dfa[Tri{CCHAR, "X", ","}] = REJECT
-	dfa[Tri{RPAREN, "X", ","}] = REJECT
-	dfa[Tri{NP, "X", ","}] = COMMA
-	dfa[Tri{COMMA, "X", ","}] = REJECT
-	dfa[Tri{CD, "X", ","}] = COMMA
-	dfa[Tri{DASH, "X", ","}] = REJECT
-	dfa[Tri{IN, "X", ","}] = REJECT
-	dfa[Tri{DT, "X", ","}] = REJECT
-	dfa[Tri{ACCEPT, "X", ","}] = REJECT
-	dfa[Tri{REJECT, "X", ","}] = REJECT
-	dfa[Tri{SYM, "X", ","}] = REJECT
-	dfa[Tri{OTHER, "X", ","}] = REJECT
-	dfa[Tri{CSYM, "X", ","}] = COMMA
-	dfa[Tri{LPARENC, "X", ","}] = REJECT
-
-	dfa[Tri{START, "X", "."}] = REJECT
-	dfa[Tri{LPAREN, "X", "."}] = REJECT
-	dfa[Tri{CCHAR, "X", "."}] = REJECT
-	dfa[Tri{RPAREN, "X", "."}] = REJECT
-	dfa[Tri{NP, "X", "."}] = ACCEPT
-	dfa[Tri{COMMA, "X", "."}] = REJECT
-	dfa[Tri{CD, "X", "."}] = ACCEPT
-	dfa[Tri{DASH, "X", "."}] = REJECT
-	dfa[Tri{IN, "X", "."}] = REJECT
-	dfa[Tri{DT, "X", "."}] = REJECT
-	dfa[Tri{ACCEPT, "X", "."}] = REJECT
-	dfa[Tri{REJECT, "X", "."}] = REJECT
-	dfa[Tri{SYM, "X", "."}] = REJECT
-	dfa[Tri{OTHER, "X", "."}] = REJECT
-	dfa[Tri{CSYM, "X", "."}] = REJECT
-	dfa[Tri{LPARENC, "X", "."}] = REJECT
-
-	dfa[Tri{START, "X", "sym"}] = SYM
-	dfa[Tri{LPAREN, "X", "sym"}] = REJECT
-	dfa[Tri{CCHAR, "X", "sym"}] = REJECT
-	dfa[Tri{RPAREN, "X", "sym"}] = REJECT
-	dfa[Tri{NP, "X", "sym"}] = SYM
-	dfa[Tri{COMMA, "X", "sym"}] = REJECT
-	dfa[Tri{CD, "X", "sym"}] = SYM
-	dfa[Tri{DASH, "X", "sym"}] = REJECT
-	dfa[Tri{IN, "X", "sym"}] = REJECT
-	dfa[Tri{DT, "X", "sym"}] = REJECT
-	dfa[Tri{ACCEPT, "X", "sym"}] = ACCEPT
-	dfa[Tri{REJECT, "X", "sym"}] = REJECT
-	dfa[Tri{SYM, "X", "sym"}] = REJECT
-	dfa[Tri{OTHER, "X", "sym"}] = REJECT
-	dfa[Tri{CSYM, "X", "sym"}] = SYM
-	dfa[Tri{LPARENC, "X", "sym"}] = REJECT
-
-	dfa[Tri{START, "X", "cc"}] = REJECT
-	dfa[Tri{LPAREN, "X", "cc"}] = REJECT
-	dfa[Tri{CCHAR, "X", "cc"}] = REJECT
-	dfa[Tri{RPAREN, "X", "cc"}] = REJECT
-	dfa[Tri{NP, "X", "cc"}] = OTHER
-	dfa[Tri{COMMA, "X", "cc"}] = REJECT
-	dfa[Tri{CD, "X", "cc"}] = OTHER
-	dfa[Tri{DASH, "X", "cc"}] = REJECT
-	dfa[Tri{IN, "X", "cc"}] = OTHER
-	dfa[Tri{DT, "X", "cc"}] = OTHER
-	dfa[Tri{ACCEPT, "X", "cc"}] = REJECT
-	dfa[Tri{REJECT, "X", "cc"}] = REJECT
-	dfa[Tri{SYM, "X", "cc"}] = REJECT
-	dfa[Tri{OTHER, "X", "cc"}] = REJECT
-	dfa[Tri{CSYM, "X", "cc"}] = OTHER
-	dfa[Tri{LPARENC, "X", "cc"}] = REJECT
-
-	dfa[Tri{START, "X", "X"}] = REJECT
-	dfa[Tri{LPAREN, "X", "X"}] = REJECT
-	dfa[Tri{CCHAR, "X", "X"}] = REJECT
-	dfa[Tri{RPAREN, "X", "X"}] = REJECT
-	dfa[Tri{NP, "X", "X"}] = ACCEPT
-	dfa[Tri{COMMA, "X", "X"}] = REJECT
-	dfa[Tri{CD, "X", "X"}] = ACCEPT
-	dfa[Tri{DASH, "X", "X"}] = REJECT
-	dfa[Tri{IN, "X", "X"}] = REJECT
-	dfa[Tri{DT, "X", "X"}] = REJECT
-	dfa[Tri{ACCEPT, "X", "X"}] = REJECT
-	dfa[Tri{REJECT, "X", "X"}] = REJECT
-	dfa[Tri{SYM, "X", "X"}] = REJECT
-	dfa[Tri{OTHER, "X", "X"}] = REJECT
-	dfa[Tri{CSYM, "X", "X"}] = REJECT
-	dfa[Tri{LPARENC, "X", "X"}] = REJECT
-
-	return symbols, dfa
-}
-
-// Given a string this will return the copyright notice
-// of that string if it exists, if not the empty string is returned
-// The string must be tagged and propperly delimited
-func (copyrightTagger *Tagger) Extract(inBytes []byte) string {
-	// Before I can match for copyright notice I need the sentence tagged
-	var taggedSent []TaggedWord
-	taggedSent = copyrightTagger.TagBytes(inBytes)
-
-	currentState := REJECT
-	var potentialNotice []TaggedWord = make([]TaggedWord, 0)
-	var extractedNotice []TaggedWord = make([]TaggedWord, 0)
-	for _, taggedWord := range taggedSent {
-
-		// Is what I have good enough to add to the extracted Notices
-		if currentState == ACCEPT {
-			extractedNotice = append(extractedNotice, potentialNotice...)
-			potentialNotice = nil
+	return len(text)
+}
+
+// The state of scanNotices: the DFA and the words of the notice it is in
+// the middle of
+type noticeScanner struct {
+	dfa   *noticeTable
+	state int
+	words []TaggedWord
+	// the notice was begun in an anchored state, and has been in a final
+	// state
+	anchored     bool
+	reachedFinal bool
+	// how many of the words the DFA had read when it was last in a final
+	// state, 0 if it hasn't been
+	finalWords int
+	// the text up to the end of the window being scanned
+	text []byte
+	// notices less confident than this are passed over; the confidence
+	// is only worked out if there is one, or it is wanted, and the words
+	// have the probabilities of their tags then
+	threshold  float64
+	confidence bool
+	found      func(notice noticeSpan) bool
+	stopped    bool
+	// records what the scanner does, for Explain; nil records nothing
+	trace *Explanation
+}
+
+// Moves the DFA on by the word, keeping the notice it ends if any
+func (scanner *noticeScanner) read(taggedWord TaggedWord) {
+	dfa := scanner.dfa
+
+	// Is what I have good enough to add to the extracted Notices
+	if scanner.state == dfa.accept {
+		scanner.emit(taggedWord.byteStart, pathAccepted)
+	}
+	// Transition to the next state given current 'input'
+	from := scanner.state
+	scanner.state = dfa.step(scanner.state, taggedWord)
+	scanner.trace.word(dfa, taggedWord, from, scanner.state)
+	// Because of multiple notices right after the other here's a check...
+	switch {
+	case dfa.begins(scanner.state):
+		if scanner.keeps(false) { // Does it seem like something useful has been captured
+			scanner.emit(taggedWord.byteStart, scanner.path())
+		} else {
+			scanner.drop(taggedWord.byteStart)
 		}
-		// Transition to the next state given current 'input'
-		if strings.ToLower(taggedWord.word) == "copyright" || strings.ToLower(taggedWord.word) == "c" {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, strings.ToLower(taggedWord.word), taggedWord.tag}]
-		} else if strings.Contains(taggedWord.word, "©") {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "©", "sym"}]
-		} else if strings.Contains(copyrightTagger.CopyrightSyms, taggedWord.tag) {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "X", taggedWord.tag}]
+		scanner.words = append(scanner.words[:0], taggedWord)
+		scanner.anchored = dfa.anchored(scanner.state)
+		scanner.reachedFinal = dfa.final(scanner.state)
+		scanner.finalWords = 0
+		if scanner.reachedFinal {
+			scanner.finalWords = 1
+		}
+	case scanner.state == dfa.reject:
+		if scanner.keeps(false) {
+			scanner.emit(taggedWord.byteStart, scanner.path())
 		} else {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "X", "X"}]
+			scanner.drop(taggedWord.byteStart)
 		}
-		// Because of multiple notices right after the other here's a check...
-		if currentState == START || currentState == LPAREN || currentState == CSYM {
-			if len(potentialNotice) > 3 { // Does it seem like something useful has been captured
-				extractedNotice = append(extractedNotice, potentialNotice...)
-				potentialNotice = nil
-				potentialNotice = append(potentialNotice, taggedWord)
-			} else {
-				potentialNotice = nil
-				potentialNotice = append(potentialNotice, taggedWord)
-			}
-		} else if currentState != REJECT { // && currentState != ACCEPT
-			potentialNotice = append(potentialNotice, taggedWord)
+		scanner.words = scanner.words[:0]
+		scanner.anchored = false
+		scanner.reachedFinal = false
+		scanner.finalWords = 0
+	default:
+		scanner.words = append(scanner.words, taggedWord)
+		if dfa.final(scanner.state) {
+			scanner.reachedFinal = true
+			scanner.finalWords = len(scanner.words)
 		}
 	}
+}
+
+// Whether the notice so far is kept without being accepted: it has more
+// than 3 words, or final says so, and wasn't begun in an anchored state
+func (scanner *noticeScanner) keeps(final bool) bool {
+	return !scanner.anchored && (final || len(scanner.words) > 3)
+}
 
-	// Do a final check to see if I might have a notice as the very last part of the string
-	// Be a little more vauge here to be safe
-	if currentState == ACCEPT || currentState == CD || currentState == NP || len(potentialNotice) > 3 {
-		extractedNotice = append(extractedNotice, potentialNotice...)
+// How the path of the DFA through the notice went, for a notice that
+// isn't accepted
+func (scanner *noticeScanner) path() float64 {
+	if scanner.reachedFinal {
+		return pathFinal
 	}
+	return pathLong
+}
 
-	if len(extractedNotice) < 1 {
-		return ""
+// Keeps the words of the notice so far, the last of which ends before
+// next, and starts over.  path is how the DFA got to the end of it.  A
+// notice that isn't accepted ends at an abbreviation the DFA was last in
+// a final state on, unless the word after it is in lower case: "Inc."
+// ends "Foo, Inc.  This program..." as the period of a sentence would,
+// the lexicon having kept it from ending the sentence, but not "Foo, Inc.
+// and others".
+func (scanner *noticeScanner) emit(next int, path float64) {
+	if len(scanner.words) == 0 || scanner.stopped {
+		return
+	}
+	if path != pathAccepted && scanner.finalWords > 0 && scanner.finalWords < len(scanner.words) &&
+		isAbbreviation(scanner.words[scanner.finalWords-1].word) &&
+		!startsLower(scanner.words[scanner.finalWords].word) {
+		next = scanner.words[scanner.finalWords].byteStart
+		scanner.words = scanner.words[:scanner.finalWords]
+	}
+	notice := noticeSpan{words: scanner.words, start: scanner.words[0].byteStart, end: scanner.end(next)}
+	if scanner.confidence {
+		notice.confidence = noticeConfidence(scanner.words, path)
+	}
+	scanner.words = nil
+	scanner.anchored = false
+	scanner.reachedFinal = false
+	scanner.finalWords = 0
+	scanner.trace.notice(scanner.text, notice, pathEnding(path), notice.confidence >= scanner.threshold)
+	if notice.confidence >= scanner.threshold {
+		scanner.stopped = !scanner.found(notice)
 	}
-	return toString(extractedNotice)
 }
 
-// similar to the regex findAllIndex, will return the byte offsets
-func (copyrightTagger *Tagger) FindAllIndex(inBytes []byte) [][]int {
-	// Before I can match for copyright notice I need the sentence tagged
-	var taggedSent []TaggedWord
-	taggedSent = copyrightTagger.TagBytes(inBytes)
+// Whether the word is an abbreviation: two letters or more ending in a
+// period, not an initial such as the "E." of "Donald E. Knuth"
+func isAbbreviation(word string) bool {
+	if word == "" || word[len(word)-1] != '.' {
+		return false
+	}
+	letters := 0
+	for _, r := range word[:len(word)-1] {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters > 1
+}
 
-	//Return array of indicies
-	var indicies = make([][]int, 0)
+// Whether the word starts with a lower case letter
+func startsLower(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsLower(r)
+}
 
-	currentState := REJECT
-	var potentialNotice []TaggedWord = make([]TaggedWord, 0)
-	for _, taggedWord := range taggedSent {
+// Passes over the words of the notice so far, the last of which ends
+// before next, when they aren't kept.  Only Explain is told of them.
+func (scanner *noticeScanner) drop(next int) {
+	if scanner.trace == nil || len(scanner.words) == 0 {
+		return
+	}
+	notice := noticeSpan{words: scanner.words, start: scanner.words[0].byteStart, end: scanner.end(next)}
+	ending := EndingShort
+	if scanner.anchored {
+		ending = EndingAnchored
+	}
+	scanner.trace.notice(scanner.text, notice, ending, false)
+}
 
-		// Is what I have good enough to add to the extracted Notices
-		if currentState == ACCEPT {
-			indicies = append(indicies, []int{potentialNotice[0].byteStart, taggedWord.byteStart})
-			potentialNotice = nil
+// The end of the last word of the notice so far, which ends before next:
+// there is nothing but space between the words
+func (scanner *noticeScanner) end(next int) int {
+	end := next
+	for end > scanner.words[len(scanner.words)-1].byteStart {
+		r, size := utf8.DecodeLastRune(scanner.text[:end])
+		if !isSpace(r) {
+			break
 		}
-		// Transition to the next state given current 'input'
-		if strings.ToLower(taggedWord.word) == "copyright" || strings.ToLower(taggedWord.word) == "c" {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, strings.ToLower(taggedWord.word), taggedWord.tag}]
-		} else if strings.Contains(taggedWord.word, "©") {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "©", "sym"}]
-		} else if strings.Contains(copyrightTagger.CopyrightSyms, taggedWord.tag) {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "X", taggedWord.tag}]
-		} else {
-			currentState = copyrightTagger.CopyrightDFA[Tri{currentState, "X", "X"}]
+		end -= size
+	}
+	return end
+}
+
+// How well the path of the DFA through a notice went, for its confidence
+const (
+	// it got to the accept state
+	pathAccepted = 1.0
+	// it went through a final state, one the text can end a notice in
+	pathFinal = 0.8
+	// it was kept only for having more than 3 words
+	pathLong = 0.4
+)
+
+// Returns the confidence in the words being a copyright notice, from 0 to
+// 1: how the DFA got through them, how probable the model found their
+// tags, and whether they have the word "copyright" or the sign, a year
+// and a holder in them
+func noticeConfidence(words []TaggedWord, path float64) float64 {
+	var tagProbs float64
+	known := 0
+	copyright, year, holder := 0.0, 0.0, 0.0
+	for _, taggedWord := range words {
+		if taggedWord.tagProb != 0 {
+			tagProbs += float64(taggedWord.tagProb)
+			known++
 		}
-		// Because of multiple notices right after the other here's a check...
-		if currentState == START || currentState == LPAREN || currentState == CSYM {
-			if len(potentialNotice) > 3 { // Does it seem like something useful has been captured
-				indicies = append(indicies, []int{potentialNotice[0].byteStart, taggedWord.byteStart})
-				potentialNotice = nil
-				potentialNotice = append(potentialNotice, taggedWord)
-			} else {
-				potentialNotice = nil
-				potentialNotice = append(potentialNotice, taggedWord)
-			}
-		} else if currentState != REJECT { // && currentState != ACCEPT
-			potentialNotice = append(potentialNotice, taggedWord)
+		if isCopyrightWord(taggedWord) {
+			copyright = 1
+		}
+		if taggedWord.tag == "cd" && hasYear(taggedWord.word) {
+			year = 1
+		}
+		if taggedWord.tag == "np" {
+			holder = 1
 		}
 	}
-
-	// Do a final check to see if I might have a notice as the very last part of the string
-	// Be a little more vauge here to be safe
-	if currentState == ACCEPT || currentState == CD || currentState == NP || len(potentialNotice) > 3 {
-		indicies = append(indicies, []int{potentialNotice[0].byteStart, potentialNotice[len(potentialNotice)-1].byteStart})
+	tags := 1.0
+	if known != 0 {
+		tags = tagProbs / float64(known)
 	}
+	return 0.25*path + 0.1*tags + 0.25*copyright + 0.2*year + 0.2*holder
+}
 
-	return indicies
+// returns true if the word is "copyright", in any language, "Copr." or
+// the copyright sign
+func isCopyrightWord(taggedWord TaggedWord) bool {
+	word := grammarWord(taggedWord.word)
+	return taggedWord.keyword == KeywordCopyright || word == "©" ||
+		strings.EqualFold(word, "copyright") || strings.EqualFold(word, "copr.")
+}
+
+// returns true if the number is, or has in it, a year from 1900 to 2099,
+// such as "2015" or "1990-2015"
+func hasYear(number string) bool {
+	digits := 0
+	for i := 0; i <= len(number); i++ {
+		if i < len(number) && '0' <= number[i] && number[i] <= '9' {
+			digits++
+			continue
+		}
+		if digits == 4 && (number[i-4:i-2] == "19" || number[i-4:i-2] == "20") {
+			return true
+		}
+		digits = 0
+	}
+	return false
 }
@@ -0,0 +1,552 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file reads tagged corpora.  Besides the corpus format the tagger
+// has always used (word|~|tag pairs separated by spaces) it reads the
+// common formats other corpora come in: Brown style word/tag pairs, CoNLL
+// columns and Penn Treebank bracketed trees.  The tags of those corpora are
+// translated to the tagger's own tag set, and a corpus with a tag that
+// can't be translated is rejected rather than quietly mis-trained.
+
+package tagger
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+)
+
+type CorpusFormat int
+
+const (
+	FormatAuto   CorpusFormat = iota // work out the format from the corpus itself
+	FormatNative                     // word|~|tag pairs separated by spaces
+	FormatBrown                      // word/tag pairs separated by spaces, also Penn Treebank .pos files
+	FormatCoNLL                      // a word per line, in columns, with blank lines between sentences
+	FormatPTB                        // Penn Treebank bracketed trees
+)
+
+var corpusFormatNames = []string{"auto", "native", "brown", "conll", "ptb"}
+
+func (format CorpusFormat) String() string {
+	if format < 0 || int(format) >= len(corpusFormatNames) {
+		return fmt.Sprintf("CorpusFormat(%d)", int(format))
+	}
+	return corpusFormatNames[format]
+}
+
+// Returns the format with the given name, as printed by String
+func ParseCorpusFormat(name string) (CorpusFormat, error) {
+	for format, formatName := range corpusFormatNames {
+		if name == formatName {
+			return CorpusFormat(format), nil
+		}
+	}
+	return FormatAuto, fmt.Errorf("unknown corpus format %q (expected one of %s)",
+		name, strings.Join(corpusFormatNames, ", "))
+}
+
+// Where and why a corpus couldn't be read.  Lines and columns count from
+// one, columns in characters; a Line of zero means the corpus as a whole.
+type CorpusError struct {
+	Path   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *CorpusError) Error() string {
+	where := e.Path
+	if e.Line != 0 {
+		if where != "" {
+			where += ":"
+		}
+		where += fmt.Sprintf("%d:%d", e.Line, e.Column)
+	}
+	if where == "" {
+		return e.Msg
+	}
+	return where + ": " + e.Msg
+}
+
+// Reads the tagged words of a corpus, in the default tag set
+func ReadCorpus(raw []byte, format CorpusFormat) ([]TaggedWord, error) {
+	return ReadCorpusTags(raw, format, DefaultTagSet())
+}
+
+// Reads the tagged words of a corpus whose tags are those of a tag set of
+// its own.  Only a native corpus can have tags of its own; the tags of
+// the other formats are translated to the default tag set.
+func ReadCorpusTags(raw []byte, format CorpusFormat, tags *TagSet) ([]TaggedWord, error) {
+	if format == FormatAuto {
+		format = detectCorpusFormat(raw)
+	}
+
+	var corpus []TaggedWord
+	var err error
+	switch format {
+	case FormatNative:
+		corpus, err = readNative(raw, tags)
+	case FormatBrown:
+		corpus, err = readBrown(raw)
+	case FormatCoNLL:
+		corpus, err = readCoNLL(raw)
+	case FormatPTB:
+		corpus, err = readPTB(raw)
+	default:
+		return nil, fmt.Errorf("unknown corpus format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(corpus) == 0 {
+		return nil, &CorpusError{Msg: fmt.Sprintf("no tagged words found (read as %s)", format)}
+	}
+
+	return corpus, nil
+}
+
+// Creates a Tagger with a model trained from the corpus in the file at
+// path, as the options say
+func NewFromCorpus(path string, format CorpusFormat, options TrainOptions) (*Tagger, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := options.Tags
+	if tags == nil {
+		tags = DefaultTagSet()
+	}
+	corpus, err := ReadCorpusTags(raw, format, tags)
+	if err != nil {
+		if cerr, ok := err.(*CorpusError); ok {
+			cerr.Path = path
+		}
+		return nil, err
+	}
+
+	signature := sha1.Sum(raw)
+
+	return train(corpus, options, hex.EncodeToString(signature[:]))
+}
+
+// Guesses the format of a corpus from its first line of words
+func detectCorpusFormat(raw []byte) CorpusFormat {
+	if bytes.Contains(raw, []byte("|~|")) {
+		return FormatNative
+	}
+
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		// skip comments (CoNLL-U) and document separators (Penn Treebank .pos)
+		if len(line) == 0 || line[0] == '#' || line[0] == '=' {
+			continue
+		}
+
+		switch {
+		case line[0] == '(':
+			return FormatPTB
+		case bytes.IndexByte(line, '\t') >= 0:
+			return FormatCoNLL
+		}
+
+		fields := bytes.Fields(line)
+		for _, field := range fields {
+			chunk := len(field) == 1 && (field[0] == '[' || field[0] == ']')
+			if bytes.IndexByte(field, '/') < 0 && !chunk && len(fields) > 1 {
+				return FormatCoNLL
+			}
+		}
+		return FormatBrown
+	}
+
+	return FormatNative
+}
+
+func isCorpusSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\f' || b == '\v'
+}
+
+// Calls fn with each space separated field of raw and the line and column
+// the field starts at, stopping at the first error fn returns
+func eachField(raw []byte, firstLine int, fn func(field string, line int, col int) error) error {
+	line := firstLine
+	lineStart := 0
+
+	for i := 0; i < len(raw); {
+		if raw[i] == '\n' {
+			line++
+			i++
+			lineStart = i
+			continue
+		}
+		if isCorpusSpace(raw[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(raw) && raw[i] != '\n' && !isCorpusSpace(raw[i]) {
+			i++
+		}
+		err := fn(string(raw[start:i]), line, utf8.RuneCount(raw[lineStart:start])+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks that word and tag make a corpus entry, with the tag translated
+// to the tagger's tag set by translate.  wordCol and tagCol are the
+// columns the word and the tag start at.
+func corpusEntry(word string, tag string, line int, wordCol int, tagCol int,
+	translate func(string) (string, bool)) (TaggedWord, error) {
+
+	if word == "" {
+		return TaggedWord{}, &CorpusError{Line: line, Column: wordCol, Msg: "missing word"}
+	}
+
+	if tag == "" {
+		return TaggedWord{}, &CorpusError{Line: line, Column: tagCol,
+			Msg: fmt.Sprintf("missing tag for %q", word)}
+	}
+
+	internal, ok := translate(tag)
+	if !ok {
+		return TaggedWord{}, &CorpusError{Line: line, Column: tagCol,
+			Msg: fmt.Sprintf("unknown tag %q for %q", tag, word)}
+	}
+
+	return TaggedWord{word: word, tag: internal}, nil
+}
+
+// The tagger's own corpus format: word|~|tag pairs separated by spaces,
+// the tags of the given tag set
+func readNative(raw []byte, tags *TagSet) ([]TaggedWord, error) {
+	var corpus []TaggedWord
+
+	err := eachField(raw, 1, func(field string, line int, col int) error {
+		sep := strings.Index(field, "|~|")
+		if sep < 0 {
+			return &CorpusError{Line: line, Column: col,
+				Msg: fmt.Sprintf("expected word|~|tag, found %q", field)}
+		}
+
+		word, tag := field[:sep], field[sep+3:]
+		taggedWord, err := corpusEntry(word, tag, line, col, col+utf8.RuneCountInString(word)+3, tags.corpusTag)
+		if err != nil {
+			return err
+		}
+		corpus = append(corpus, taggedWord)
+		return nil
+	})
+
+	return corpus, err
+}
+
+// Brown corpus style word/tag pairs separated by spaces.  The tagged .pos
+// files of the Penn Treebank are in the same form, with chunks in [ ] and
+// documents separated by lines of =, which are skipped.
+func readBrown(raw []byte) ([]TaggedWord, error) {
+	var corpus []TaggedWord
+
+	err := eachField(raw, 1, func(field string, line int, col int) error {
+		if field == "[" || field == "]" || strings.Trim(field, "=") == "" {
+			return nil
+		}
+
+		sep := strings.LastIndex(field, "/")
+		if sep < 0 {
+			return &CorpusError{Line: line, Column: col,
+				Msg: fmt.Sprintf("expected word/tag, found %q", field)}
+		}
+
+		word, tag := field[:sep], field[sep+1:]
+		taggedWord, err := corpusEntry(word, tag, line, col, col+utf8.RuneCountInString(word)+1, foreignTag)
+		if err != nil {
+			return err
+		}
+		taggedWord.word = pennWord(strings.Replace(taggedWord.word, "\\/", "/", -1))
+		corpus = append(corpus, taggedWord)
+		return nil
+	})
+
+	return corpus, err
+}
+
+// CoNLL style columns, a word per line.  Lines of six or more columns are
+// numbered CoNLL-X / CoNLL-U lines (ID FORM LEMMA CPOSTAG POSTAG ...) and
+// take the fine grained tag if there is one; shorter lines (CoNLL-2000,
+// CoNLL-2003) are the word followed by its tag.
+func readCoNLL(raw []byte) ([]TaggedWord, error) {
+	var corpus []TaggedWord
+
+	for i, rawLine := range bytes.Split(raw, []byte("\n")) {
+		line := i + 1
+		if len(bytes.TrimSpace(rawLine)) == 0 || rawLine[0] == '#' {
+			continue
+		}
+
+		var cols []string
+		var colStarts []int
+		if bytes.IndexByte(rawLine, '\t') >= 0 {
+			start := 0
+			for _, col := range strings.Split(strings.TrimRight(string(rawLine), "\r"), "\t") {
+				cols = append(cols, col)
+				colStarts = append(colStarts, utf8.RuneCount(rawLine[:start])+1)
+				start += len(col) + 1
+			}
+		} else {
+			eachField(rawLine, line, func(field string, line int, col int) error {
+				cols = append(cols, field)
+				colStarts = append(colStarts, col)
+				return nil
+			})
+		}
+
+		if len(cols) < 2 {
+			return nil, &CorpusError{Line: line, Column: colStarts[0],
+				Msg: fmt.Sprintf("expected a word and its tag, found %q", strings.TrimSpace(string(rawLine)))}
+		}
+
+		word, tag := 0, 1
+		if len(cols) >= 6 {
+			// multiword tokens (1-2) and empty nodes (8.1) aren't words
+			if strings.ContainsAny(cols[0], "-.") {
+				continue
+			}
+			word, tag = 1, 4
+			if cols[tag] == "_" {
+				tag = 3
+			}
+		}
+		if cols[word] == "-DOCSTART-" {
+			continue
+		}
+
+		taggedWord, err := corpusEntry(cols[word], cols[tag], line, colStarts[word], colStarts[tag], foreignTag)
+		if err != nil {
+			return nil, err
+		}
+		taggedWord.word = pennWord(taggedWord.word)
+		corpus = append(corpus, taggedWord)
+	}
+
+	return corpus, nil
+}
+
+// a parenthesis or an atom of a Penn Treebank tree and where it starts
+type ptbToken struct {
+	text string
+	line int
+	col  int
+}
+
+// Penn Treebank bracketed trees.  The tagged words are the leaves, such as
+// (NN dog); traces and other empty elements, (-NONE- *T*-1), are skipped.
+func readPTB(raw []byte) ([]TaggedWord, error) {
+	var tokens []ptbToken
+
+	eachField(raw, 1, func(field string, line int, col int) error {
+		for field != "" {
+			n := strings.IndexAny(field, "()")
+			switch {
+			case n < 0:
+				n = len(field)
+			case n == 0:
+				n = 1
+			}
+			tokens = append(tokens, ptbToken{text: field[:n], line: line, col: col})
+			col += utf8.RuneCountInString(field[:n])
+			field = field[n:]
+		}
+		return nil
+	})
+
+	var corpus []TaggedWord
+	var open []ptbToken
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.text {
+		case "(":
+			// a leaf is ( tag word )
+			if i+3 < len(tokens) && !isParen(tokens[i+1].text) && !isParen(tokens[i+2].text) && tokens[i+3].text == ")" {
+				tag, word := tokens[i+1], tokens[i+2]
+				i += 3
+				if tag.text == "-NONE-" {
+					continue
+				}
+				internal, ok := foreignTag(tag.text)
+				if !ok {
+					return nil, &CorpusError{Line: tag.line, Column: tag.col,
+						Msg: fmt.Sprintf("unknown tag %q for %q", tag.text, word.text)}
+				}
+				corpus = append(corpus, TaggedWord{word: pennWord(word.text), tag: internal})
+				continue
+			}
+			open = append(open, tok)
+		case ")":
+			if len(open) == 0 {
+				return nil, &CorpusError{Line: tok.line, Column: tok.col, Msg: "unbalanced )"}
+			}
+			open = open[:len(open)-1]
+		default:
+			// a phrase label follows its (, anything else is a stray word
+			if i == 0 || tokens[i-1].text != "(" {
+				return nil, &CorpusError{Line: tok.line, Column: tok.col,
+					Msg: fmt.Sprintf("expected (tag word), found %q", tok.text)}
+			}
+		}
+	}
+
+	if len(open) != 0 {
+		tok := open[len(open)-1]
+		return nil, &CorpusError{Line: tok.line, Column: tok.col, Msg: "unbalanced ("}
+	}
+
+	return corpus, nil
+}
+
+func isParen(text string) bool {
+	return text == "(" || text == ")"
+}
+
+// Penn Treebank spells out brackets and quotes that would otherwise be
+// mistaken for markup; these are the words as the tagger sees them
+var pennWords = map[string]string{
+	"-LRB-": "(", "-RRB-": ")",
+	"-LCB-": "{", "-RCB-": "}",
+	"-LSB-": "[", "-RSB-": "]",
+	"``": "\"", "''": "\"",
+}
+
+func pennWord(word string) string {
+	if w, ok := pennWords[word]; ok {
+		return w
+	}
+	return word
+}
+
+// Tags of the default tag set, which the tags of foreign corpora are
+// translated to.  bos is internal to the tagger and never given to a word.
+func nativeTag(tag string) (string, bool) {
+	return defaultTags.corpusTag(tag)
+}
+
+// Translation of the Penn Treebank tag set to the tagger's own
+var pennTags = map[string]string{
+	"CC": "cc", "CD": "cd", "DT": "dt", "PDT": "dt", "WDT": "dt",
+	"EX": "pr", "FW": "fw", "IN": "in", "LS": "ls", "MD": "md",
+	"JJ": "jj", "JJR": "jj", "JJS": "jj",
+	"NN": "nn", "NNS": "nn", "NNP": "np", "NNPS": "np", "POS": "pos",
+	"PRP": "pr", "PRP$": "pr", "WP": "pr", "WP$": "pr",
+	"RB": "rb", "RBR": "rb", "RBS": "rb", "RP": "rb", "WRB": "rb",
+	"SYM": "sym", "TO": "to", "UH": "uh",
+	"VB": "vb", "VBD": "vb", "VBG": "vb", "VBN": "vb", "VBP": "vb", "VBZ": "vb",
+	"$": "$", "#": "sym", "``": "\"", "''": "\"",
+	"-LRB-": "(", "-RRB-": ")", "-LCB-": "(", "-RCB-": ")", "-LSB-": "(", "-RSB-": ")",
+	",": ",", ".": ".", ":": ":",
+	// OntoNotes and the English Web Treebank add these
+	"HYPH": "--", "NFP": "sym", "ADD": "fw", "AFX": "jj", "GW": "fw", "XX": "fw",
+}
+
+// The tags of a foreign corpus may be the tagger's own, Penn Treebank tags
+// or Brown corpus tags, in either case
+func foreignTag(tag string) (string, bool) {
+	if internal, ok := nativeTag(tag); ok {
+		return internal, true
+	}
+	if internal, ok := pennTags[tag]; ok {
+		return internal, true
+	}
+	return brownTag(strings.ToLower(tag))
+}
+
+// Translation of the (much larger) Brown corpus tag set to the tagger's own
+func brownTag(tag string) (string, bool) {
+	if strings.HasPrefix(tag, "fw-") {
+		return "fw", true
+	}
+	// contractions are tagged with both parts, ppss+md for "I'd"
+	if plus := strings.Index(tag, "+"); plus > 0 {
+		tag = tag[:plus]
+	}
+	// titles, headlines and cited words are marked with suffixes
+	for _, suffix := range []string{"-tl", "-hl", "-nc"} {
+		tag = strings.Replace(tag, suffix, "", -1)
+	}
+	if len(tag) > 1 {
+		tag = strings.TrimSuffix(tag, "$") // possessives, nn$ for "dog's"
+	}
+
+	if internal, ok := nativeTag(tag); ok {
+		return internal, true
+	}
+
+	switch tag {
+	case "``", "''":
+		return "\"", true
+	case "*": // not, n't
+		return "rb", true
+	case "ex":
+		return "pr", true
+	case "cs":
+		return "in", true
+	case "od":
+		return "cd", true
+	case "nr", "nrs": // adverbial nouns, home and today
+		return "nn", true
+	case "ql", "qlp", "rp", "rn", "wql":
+		return "rb", true
+	}
+
+	prefixes := []struct {
+		prefix string
+		tag    string
+	}{
+		{"wrb", "rb"}, {"wdt", "dt"}, {"wp", "pr"}, {"pp", "pr"},
+		{"nn", "nn"}, {"np", "np"}, {"vb", "vb"}, {"be", "vb"}, {"hv", "vb"}, {"do", "vb"},
+		{"jj", "jj"}, {"rb", "rb"}, {"cd", "cd"}, {"at", "dt"}, {"ap", "dt"}, {"ab", "dt"}, {"dt", "dt"},
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(tag, p.prefix) {
+			return p.tag, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,399 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file measures how well a tagger model does.  The part of speech
+// tags it gives are compared against a tagged corpus, either the one it
+// was trained from, a held out part of it, or each fold of a k-fold cross
+// validation.  Copyright detection as a whole is measured against a set of
+// texts labeled with whether or not they hold a copyright notice.
+
+package tagger
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Evaluation counts, for each tag the corpus gives a word, the tags the
+// tagger gave it: Confusion[corpus tag][tagger tag], indexed by Tags
+type Evaluation struct {
+	Tags      *TagSet
+	Confusion [][]int
+}
+
+func NewEvaluation(tags *TagSet) *Evaluation {
+	var confusion = make([][]int, tags.Len())
+	for row := range confusion {
+		confusion[row] = make([]int, tags.Len())
+	}
+
+	return &Evaluation{Tags: tags, Confusion: confusion}
+}
+
+// Adds the counts of another evaluation to this one, to total up folds
+func (e *Evaluation) Add(other *Evaluation) {
+	for row := range e.Confusion {
+		for col := range e.Confusion[row] {
+			e.Confusion[row][col] += other.Confusion[row][col]
+		}
+	}
+}
+
+func (e *Evaluation) Total() int {
+	total := 0
+	for row := range e.Confusion {
+		for col := range e.Confusion[row] {
+			total += e.Confusion[row][col]
+		}
+	}
+	return total
+}
+
+func (e *Evaluation) Correct() int {
+	correct := 0
+	for tag := range e.Confusion {
+		correct += e.Confusion[tag][tag]
+	}
+	return correct
+}
+
+func (e *Evaluation) Accuracy() float64 {
+	return ratio(e.Correct(), e.Total())
+}
+
+// The number of words the corpus gives the tag
+func (e *Evaluation) Gold(tag int) int {
+	n := 0
+	for col := range e.Confusion[tag] {
+		n += e.Confusion[tag][col]
+	}
+	return n
+}
+
+// The number of words the tagger gave the tag
+func (e *Evaluation) Tagged(tag int) int {
+	n := 0
+	for row := range e.Confusion {
+		n += e.Confusion[row][tag]
+	}
+	return n
+}
+
+// Of the words the tagger gave the tag, the fraction the corpus agrees on
+func (e *Evaluation) Precision(tag int) float64 {
+	return ratio(e.Confusion[tag][tag], e.Tagged(tag))
+}
+
+// Of the words the corpus gives the tag, the fraction the tagger found
+func (e *Evaluation) Recall(tag int) float64 {
+	return ratio(e.Confusion[tag][tag], e.Gold(tag))
+}
+
+func ratio(n int, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// Writes the per tag precision and recall followed by the confusion
+// matrix.  Tags that neither the corpus nor the tagger used are left out.
+func (e *Evaluation) WriteReport(w io.Writer) error {
+	outb := bufio.NewWriter(w)
+
+	var tags []int
+	for tag := range e.Confusion {
+		if e.Gold(tag) != 0 || e.Tagged(tag) != 0 {
+			tags = append(tags, tag)
+		}
+	}
+
+	fmt.Fprintf(outb, "%-5s %7s %7s %7s %9s %7s\n", "tag", "corpus", "tagged", "correct", "precision", "recall")
+	for _, tag := range tags {
+		fmt.Fprintf(outb, "%-5s %7d %7d %7d %9.3f %7.3f\n", e.Tags.Name(tag),
+			e.Gold(tag), e.Tagged(tag), e.Confusion[tag][tag], e.Precision(tag), e.Recall(tag))
+	}
+	fmt.Fprintf(outb, "\naccuracy %d/%d = %.3f\n", e.Correct(), e.Total(), e.Accuracy())
+
+	fmt.Fprintf(outb, "\nconfusion matrix (rows: corpus tag, columns: tagger tag)\n%-5s", "")
+	for _, col := range tags {
+		fmt.Fprintf(outb, " %4s", e.Tags.Name(col))
+	}
+	fmt.Fprintf(outb, "\n")
+	for _, row := range tags {
+		fmt.Fprintf(outb, "%-5s", e.Tags.Name(row))
+		for _, col := range tags {
+			if e.Confusion[row][col] == 0 {
+				fmt.Fprintf(outb, " %4s", ".")
+			} else {
+				fmt.Fprintf(outb, " %4d", e.Confusion[row][col])
+			}
+		}
+		fmt.Fprintf(outb, "\n")
+	}
+
+	return outb.Flush()
+}
+
+// Splits the words of a corpus into sentences, each ending with a word
+// tagged as a sentence terminator
+func splitSentences(corpus []TaggedWord) [][]TaggedWord {
+	var sentences [][]TaggedWord
+
+	start := 0
+	for i, taggedWord := range corpus {
+		if taggedWord.tag == "." || i == len(corpus)-1 {
+			sentences = append(sentences, corpus[start:i+1])
+			start = i + 1
+		}
+	}
+
+	return sentences
+}
+
+// Tags the words of the sentences, one sentence at a time, and counts
+// them into the evaluation
+func (copyrightTagger *Tagger) evaluate(e *Evaluation, sentences [][]TaggedWord) {
+	for _, sentence := range sentences {
+		var wrdArry = make([]TaggedWord, len(sentence))
+		for i := range sentence {
+			wrdArry[i].word = sentence[i].word
+		}
+
+		copyrightTagger.Model.TagWords(wrdArry)
+		for i := range sentence {
+			// a corpus tag the model doesn't have can't be counted
+			gold, ok := e.Tags.Index(sentence[i].tag)
+			if !ok {
+				continue
+			}
+			tagged, _ := e.Tags.Index(wrdArry[i].tag)
+			e.Confusion[gold][tagged]++
+		}
+	}
+}
+
+// Compares the tags the tagger gives the words of a tagged corpus with
+// the tags the corpus gives them
+func (copyrightTagger *Tagger) Evaluate(corpus []TaggedWord) *Evaluation {
+	e := NewEvaluation(copyrightTagger.Model.Tags())
+	copyrightTagger.evaluate(e, splitSentences(corpus))
+
+	return e
+}
+
+// Tags the words of a tagged corpus and returns how many of them got the
+// same tag as the corpus gives them.  This is a measure of how accurate
+// the tagger is.
+func (copyrightTagger *Tagger) Accuracy(corpus []TaggedWord) (correct int, total int) {
+	e := copyrightTagger.Evaluate(corpus)
+
+	return e.Correct(), e.Total()
+}
+
+// k-fold cross validation: the sentences of the corpus are dealt out
+// round robin into folds, and each fold is tagged by a model trained on
+// all of the other folds.  Returns the evaluation of each fold.
+func CrossValidate(corpus []TaggedWord, folds int, options TrainOptions) ([]*Evaluation, error) {
+	sentences := splitSentences(corpus)
+	if folds < 2 || folds > len(sentences) {
+		return nil, fmt.Errorf("can't make %d folds out of %d sentences", folds, len(sentences))
+	}
+
+	var evaluations []*Evaluation
+	for fold := 0; fold < folds; fold++ {
+		e, err := evaluateFold(sentences, folds, fold, options)
+		if err != nil {
+			return nil, err
+		}
+		evaluations = append(evaluations, e)
+	}
+
+	return evaluations, nil
+}
+
+// Trains on all but a held out fraction of the sentences of the corpus,
+// every 1/fraction'th sentence, and evaluates on the held out ones
+func HoldOut(corpus []TaggedWord, fraction float64, options TrainOptions) (*Evaluation, error) {
+	sentences := splitSentences(corpus)
+	if fraction <= 0 || fraction >= 1 {
+		return nil, fmt.Errorf("held out fraction %g is not between 0 and 1", fraction)
+	}
+
+	folds := int(1/fraction + 0.5)
+	if folds < 2 || folds > len(sentences) {
+		return nil, fmt.Errorf("can't hold out %g of %d sentences", fraction, len(sentences))
+	}
+
+	return evaluateFold(sentences, folds, 0, options)
+}
+
+func evaluateFold(sentences [][]TaggedWord, folds int, fold int, options TrainOptions) (*Evaluation, error) {
+	var training []TaggedWord
+	var testing [][]TaggedWord
+
+	for i, sentence := range sentences {
+		if i%folds == fold {
+			testing = append(testing, sentence)
+		} else {
+			training = append(training, sentence...)
+		}
+	}
+
+	copyrightTagger, err := train(training, options, "")
+	if err != nil {
+		return nil, err
+	}
+
+	e := NewEvaluation(copyrightTagger.Model.Tags())
+	copyrightTagger.evaluate(e, testing)
+
+	return e, nil
+}
+
+// A text labeled with whether or not it holds a copyright notice
+type LabeledNotice struct {
+	Line      int  // line of the labeled notices file the text starts on
+	Copyright bool // does the text hold a copyright notice
+	Text      []byte
+}
+
+// Reads a file of labeled texts.  Each text is preceded by a line
+// starting with "%%", followed by "+" if the text holds a copyright
+// notice or "-" if it doesn't.  Anything before the first "%%" line is
+// ignored, so the file can start with a description of itself.
+//
+//	%% +
+//	Copyright (c) 2015 Exablox Corporation
+//	%% -
+//	#define Copyright sign
+func ReadLabeledNotices(r io.Reader) ([]LabeledNotice, error) {
+	var notices []LabeledNotice
+	var text bytes.Buffer
+
+	flush := func() {
+		if len(notices) != 0 {
+			notices[len(notices)-1].Text = bytes.TrimSuffix(append([]byte(nil), text.Bytes()...), []byte("\n"))
+		}
+		text.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if !strings.HasPrefix(scanner.Text(), "%%") {
+			text.WriteString(scanner.Text())
+			text.WriteByte('\n')
+			continue
+		}
+
+		flush()
+		switch strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "%%")) {
+		case "+":
+			notices = append(notices, LabeledNotice{Line: line + 1, Copyright: true})
+		case "-":
+			notices = append(notices, LabeledNotice{Line: line + 1, Copyright: false})
+		default:
+			return nil, fmt.Errorf("line %d: expected \"%%%% +\" or \"%%%% -\"", line)
+		}
+	}
+	flush()
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return notices, nil
+}
+
+// How copyright detection did on a set of labeled texts
+type Detection struct {
+	TruePos  int
+	FalsePos int
+	TrueNeg  int
+	FalseNeg int
+
+	Missed   []LabeledNotice // copyright notices that weren't detected
+	Spurious []LabeledNotice // texts detected as copyright notices that aren't
+}
+
+// Of the texts holding a copyright notice, the fraction detected
+func (d *Detection) Recall() float64 {
+	return ratio(d.TruePos, d.TruePos+d.FalseNeg)
+}
+
+// Of the texts detected as holding a copyright notice, the fraction that do
+func (d *Detection) Precision() float64 {
+	return ratio(d.TruePos, d.TruePos+d.FalsePos)
+}
+
+// Runs copyright detection (Match) over each of the labeled texts
+func (copyrightTagger *Tagger) Detect(notices []LabeledNotice) *Detection {
+	d := &Detection{}
+
+	for _, n := range notices {
+		found := copyrightTagger.Match(n.Text)
+		switch {
+		case found && n.Copyright:
+			d.TruePos++
+		case found && !n.Copyright:
+			d.FalsePos++
+			d.Spurious = append(d.Spurious, n)
+		case !found && n.Copyright:
+			d.FalseNeg++
+			d.Missed = append(d.Missed, n)
+		default:
+			d.TrueNeg++
+		}
+	}
+
+	return d
+}
+
+// Writes the detection recall and precision, and where to find each
+// text that was missed or spuriously detected
+func (d *Detection) WriteReport(w io.Writer, path string) error {
+	outb := bufio.NewWriter(w)
+
+	fmt.Fprintf(outb, "copyright detection: recall %d/%d = %.3f, precision %d/%d = %.3f\n",
+		d.TruePos, d.TruePos+d.FalseNeg, d.Recall(),
+		d.TruePos, d.TruePos+d.FalsePos, d.Precision())
+	for _, n := range d.Missed {
+		fmt.Fprintf(outb, "%s:%d: missed copyright notice\n", path, n.Line)
+	}
+	for _, n := range d.Spurious {
+		fmt.Fprintf(outb, "%s:%d: spurious copyright notice\n", path, n.Line)
+	}
+
+	return outb.Flush()
+}
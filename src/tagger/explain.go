@@ -0,0 +1,280 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file explains how the tagger came to find the copyright notices it
+// found in a text, or not to: the windows of the text around the hits of
+// the Prefilter, the sentences each was split into, the tag each word was
+// given and how probable the model found it, the state of the grammar's
+// DFA after each word, and every notice the DFA ended, kept or not.
+
+package tagger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// What the tagger did with a text, see Explain.  Offsets are byte offsets
+// in Text.  It marshals to JSON, for bug reports.
+type Explanation struct {
+	Text      string            `json:"text"`
+	Threshold float64           `json:"threshold"`
+	Windows   []ExplainedWindow `json:"windows"`
+	Notices   []ExplainedNotice `json:"notices"`
+}
+
+// A window of the text around hits of the Prefilter, the only parts of it
+// that are scanned for notices
+type ExplainedWindow struct {
+	Start     int                 `json:"start"`
+	End       int                 `json:"end"`
+	Sentences []ExplainedSentence `json:"sentences"`
+}
+
+// A sentence of a window, the words of which are tagged together
+type ExplainedSentence struct {
+	Start int             `json:"start"`
+	End   int             `json:"end"`
+	Words []ExplainedWord `json:"words"`
+}
+
+// A word and its tag, and the states of the DFA before and after it
+type ExplainedWord struct {
+	Word  string `json:"word"`
+	Start int    `json:"start"`
+	Tag   string `json:"tag"`
+	// how probable the model found the tag, 0 for a model that doesn't say
+	Prob float32 `json:"prob"`
+	// for a keyword of another language, the English one it is read as
+	// and its language
+	Keyword  string `json:"keyword,omitempty"`
+	Language string `json:"language,omitempty"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	// what the word does to the notice: EventBegin, EventAccept,
+	// EventReject, or "" for nothing of note
+	Event string `json:"event,omitempty"`
+}
+
+// What a word does to the notice the DFA is reading
+const (
+	// a notice begins with the word
+	EventBegin = "begin"
+	// the notice so far is complete
+	EventAccept = "accept"
+	// the words so far aren't a notice
+	EventReject = "reject"
+)
+
+// A notice the DFA ended, whether it was kept or not
+type ExplainedNotice struct {
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	Text       string  `json:"text"`
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+	// how it ended, one of the Ending constants; a notice that is too short
+	// or anchored has no confidence
+	Ending string `json:"ending"`
+	// whether it is one of the notices found: it was ended in a way that
+	// keeps it, and is at least as confident as the Threshold
+	Kept bool `json:"kept"`
+}
+
+// How the DFA ended a notice, see scanNotices
+const (
+	// it got to the accept state
+	EndingAccepted = "accepted"
+	// it went through a final state
+	EndingFinal = "final"
+	// it has more than 3 words
+	EndingLong = "long"
+	// it has 3 words or fewer and went through no final state, so it isn't
+	// kept
+	EndingShort = "short"
+	// it was begun in an anchored state and didn't get to the accept
+	// state, so it isn't kept
+	EndingAnchored = "anchored"
+)
+
+// The ending of a notice kept by the path the DFA took through it
+func pathEnding(path float64) string {
+	switch path {
+	case pathAccepted:
+		return EndingAccepted
+	case pathFinal:
+		return EndingFinal
+	}
+	return EndingLong
+}
+
+// Finds the copyright notices in the text as FindAll does, and returns how
+// it went about it
+func (copyrightTagger *Tagger) Explain(inBytes []byte) *Explanation {
+	explanation := &Explanation{Text: string(inBytes), Threshold: copyrightTagger.Threshold}
+	copyrightTagger.traceNotices(inBytes, explanation, true, func(notice noticeSpan) bool {
+		return true
+	})
+	return explanation
+}
+
+// The notices found, as FindAll returns them
+func (explanation *Explanation) Found() []Notice {
+	var notices []Notice
+	for _, notice := range explanation.Notices {
+		if notice.Kept {
+			notices = append(notices, Notice{Start: notice.Start, End: notice.End, Language: notice.Language,
+				Confidence: notice.Confidence})
+		}
+	}
+	return notices
+}
+
+// The methods below record what scanNotices does; on a nil explanation
+// they do nothing
+
+func (explanation *Explanation) window(start int, end int) {
+	if explanation == nil {
+		return
+	}
+	explanation.Windows = append(explanation.Windows, ExplainedWindow{Start: start, End: end})
+}
+
+func (explanation *Explanation) sentence(start int, end int) {
+	if explanation == nil {
+		return
+	}
+	window := &explanation.Windows[len(explanation.Windows)-1]
+	window.Sentences = append(window.Sentences, ExplainedSentence{Start: start, End: end})
+}
+
+func (explanation *Explanation) word(dfa *noticeTable, taggedWord TaggedWord, from int, to int) {
+	if explanation == nil {
+		return
+	}
+	var event string
+	switch {
+	case dfa.begins(to):
+		event = EventBegin
+	case to == dfa.accept:
+		event = EventAccept
+	case to == dfa.reject && from != dfa.reject:
+		event = EventReject
+	}
+
+	window := &explanation.Windows[len(explanation.Windows)-1]
+	sentence := &window.Sentences[len(window.Sentences)-1]
+	sentence.Words = append(sentence.Words, ExplainedWord{Word: taggedWord.word, Start: taggedWord.byteStart,
+		Tag: taggedWord.tag, Prob: taggedWord.tagProb, Keyword: taggedWord.keyword, Language: taggedWord.lang,
+		From: dfa.grammar.states[from].name, To: dfa.grammar.states[to].name, Event: event})
+}
+
+func (explanation *Explanation) notice(text []byte, notice noticeSpan, ending string, kept bool) {
+	if explanation == nil {
+		return
+	}
+	explanation.Notices = append(explanation.Notices, ExplainedNotice{Start: notice.start, End: notice.end,
+		Text: string(text[notice.start:notice.end]), Language: notice.language(), Confidence: notice.confidence,
+		Ending: ending, Kept: kept})
+}
+
+// ANSI terminal colors for WriteReport
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorBlue   = "\x1b[34m"
+	colorCyan   = "\x1b[36m"
+	colorGray   = "\x1b[90m"
+)
+
+// Tags the model found less probable than this are marked in the report
+const doubtfulTagProb = 0.5
+
+// Writes the explanation for people to read: each window and sentence,
+// a line for each word, and the notices.  With color the words that begin,
+// accept or reject a notice, doubtful tags, and the notices kept and
+// passed over are colored for a terminal.
+func (explanation *Explanation) WriteReport(w io.Writer, color bool) error {
+	outb := bufio.NewWriter(w)
+	paint := func(code string, text string) string {
+		if !color || code == "" {
+			return text
+		}
+		return code + text + colorReset
+	}
+
+	if len(explanation.Windows) == 0 {
+		fmt.Fprintf(outb, "%s\n", paint(colorYellow, "no hits of the prefilter: the text wasn't scanned"))
+	}
+	for _, window := range explanation.Windows {
+		fmt.Fprintf(outb, "%s\n", paint(colorBold, fmt.Sprintf("window %d-%d", window.Start, window.End)))
+		for _, sentence := range window.Sentences {
+			fmt.Fprintf(outb, "  %s %q\n", paint(colorBlue, fmt.Sprintf("sentence %d-%d", sentence.Start, sentence.End)),
+				explanation.Text[sentence.Start:sentence.End])
+			for _, word := range sentence.Words {
+				prob := fmt.Sprintf("%4.2f", word.Prob)
+				if word.Prob != 0 && word.Prob < doubtfulTagProb {
+					prob = paint(colorYellow, prob)
+				}
+				keyword := ""
+				if word.Keyword != "" {
+					keyword = paint(colorCyan, fmt.Sprintf(" =%s (%s)", word.Keyword, word.Language))
+				}
+				eventColor := map[string]string{EventBegin: colorGreen, EventAccept: colorGreen, EventReject: colorRed}[word.Event]
+				line := fmt.Sprintf("    %6d %-20s %-5s %s %10s -> %-10s %s%s", word.Start, word.Word, word.Tag, prob,
+					word.From, word.To, paint(eventColor, word.Event), keyword)
+				fmt.Fprintf(outb, "%s\n", strings.TrimRight(line, " "))
+			}
+		}
+	}
+
+	fmt.Fprintf(outb, "%s\n", paint(colorBold, fmt.Sprintf("notices (threshold %.2f)", explanation.Threshold)))
+	if len(explanation.Notices) == 0 {
+		fmt.Fprintf(outb, "  %s\n", paint(colorGray, "none"))
+	}
+	for _, notice := range explanation.Notices {
+		status := paint(colorGreen, "kept")
+		if !notice.Kept {
+			status = paint(colorRed, "passed over")
+		}
+		// a notice that isn't kept for its ending has no confidence
+		confidence := ""
+		if notice.Ending != EndingShort && notice.Ending != EndingAnchored {
+			confidence = fmt.Sprintf(", confidence %.2f", notice.Confidence)
+		}
+		fmt.Fprintf(outb, "  %d-%d %s: %s%s, %s\n    %q\n", notice.Start, notice.End, status,
+			notice.Ending, confidence, notice.Language, notice.Text)
+	}
+
+	return outb.Flush()
+}
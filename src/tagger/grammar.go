@@ -0,0 +1,309 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+// This file is the grammar of copyright notices: the DFA that Match,
+// Extract and FindAllIndex drive with the tagged words of the text, read
+// from a state table rather than written in Go, so that what counts as a
+// notice can be changed without changing the code.  The grammar is
+// checked as it is read: every state has a transition on every input, and
+// every word is one of the inputs.
+//
+// A default grammar, the DFA the package has always had extended to
+// "Portions Copyright", "Copr.", "Author:", "Written by" and holders
+// followed by "All rights reserved", is embedded in the package; others
+// can be read from a file in the same format, see ReadGrammar().
+
+package tagger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The grammar every Tagger starts with
+//
+//go:embed DefaultGrammar.txt
+var defaultGrammar []byte
+
+// A copyright notice grammar, see ReadGrammar().  A grammar never changes
+// once read, so it can be shared.
+type NoticeGrammar struct {
+	states []grammarState
+	inputs []grammarInput
+	// next[state][input] is the state the DFA goes to
+	next [][]int
+
+	initial int
+	accept  int
+	reject  int
+}
+
+type grammarState struct {
+	name string
+	// a notice begins with the word that leads to the state
+	begin bool
+	// a notice the text ends in while in the state is kept
+	final bool
+	// a notice begun in the state is only kept if it is accepted
+	anchored bool
+}
+
+// A kind of word: the word, in any case, and its tag, "" for any
+type grammarInput struct {
+	name string
+	word string
+	tag  string
+}
+
+// the roles a state can have
+var grammarRoles = []string{"initial", "begin", "accept", "reject", "final", "anchored"}
+
+// Returns the default grammar, from DefaultGrammar.txt
+func DefaultGrammar() *NoticeGrammar {
+	grammar, err := ReadGrammar(bytes.NewReader(defaultGrammar))
+	if err != nil {
+		panic("tagger: DefaultGrammar.txt: " + err.Error())
+	}
+	return grammar
+}
+
+// Reads a grammar from r.  Blank lines and lines starting with # are
+// skipped, the others are one of:
+//
+//	state NAME ROLE...
+//	input NAME word=WORD tag=TAG
+//	next STATE TO...
+//
+// A state has any of the roles initial (the state before the first word),
+// begin (a notice begins with the word that leads to it), accept (the
+// notice so far is complete), reject (the words so far aren't a notice)
+// final (a notice the text ends in while in it is kept) and anchored (a
+// notice begun in it is only kept if it reaches the accept state, such as
+// a name with "All rights reserved" after it but no "copyright"); exactly
+// one state is the initial, accept and reject state, and an anchored
+// state is a begin state.  An input is a kind of
+// word, WORD in any case with the part of speech tag TAG; either can be
+// left out to match any.  A word is the first input it matches, and the
+// last input must match any word.  The next line of a state gives the
+// state to go to on each input, in the order of the inputs.
+func ReadGrammar(r io.Reader) (*NoticeGrammar, error) {
+	grammar := &NoticeGrammar{initial: -1, accept: -1, reject: -1}
+	stateIndex := make(map[string]int)
+	inputIndex := make(map[string]bool)
+
+	// the next lines are read once all of the states are known
+	type nextLine struct {
+		line   int
+		fields []string
+	}
+	var nextLines []nextLine
+
+	line := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: %s has no name", line, fields[0])
+		}
+
+		switch fields[0] {
+		case "state":
+			name := fields[1]
+			if _, ok := stateIndex[name]; ok {
+				return nil, fmt.Errorf("line %d: state %s is defined twice", line, name)
+			}
+			stateIndex[name] = len(grammar.states)
+			state := grammarState{name: name}
+			for _, role := range fields[2:] {
+				var only *int
+				switch role {
+				case "initial":
+					only = &grammar.initial
+				case "accept":
+					only = &grammar.accept
+				case "reject":
+					only = &grammar.reject
+				case "begin":
+					state.begin = true
+				case "final":
+					state.final = true
+				case "anchored":
+					state.anchored = true
+				default:
+					return nil, fmt.Errorf("line %d: unknown role %q, the roles are %s", line, role, strings.Join(grammarRoles, ", "))
+				}
+				if only != nil {
+					if *only >= 0 {
+						return nil, fmt.Errorf("line %d: %s is the %s state as well as %s", line, name, role, grammar.states[*only].name)
+					}
+					*only = len(grammar.states)
+				}
+			}
+			if state.anchored && !state.begin {
+				return nil, fmt.Errorf("line %d: %s is anchored but no notice begins in it", line, name)
+			}
+			grammar.states = append(grammar.states, state)
+
+		case "input":
+			input := grammarInput{name: fields[1]}
+			if inputIndex[input.name] {
+				return nil, fmt.Errorf("line %d: input %s is defined twice", line, input.name)
+			}
+			inputIndex[input.name] = true
+			for _, field := range fields[2:] {
+				switch {
+				case strings.HasPrefix(field, "word=") && len(field) > len("word="):
+					input.word = field[len("word="):]
+				case strings.HasPrefix(field, "tag=") && len(field) > len("tag="):
+					input.tag = field[len("tag="):]
+				default:
+					return nil, fmt.Errorf("line %d: %q is neither word=WORD nor tag=TAG", line, field)
+				}
+			}
+			if len(grammar.inputs) > 0 && grammar.inputs[len(grammar.inputs)-1].matchesAny() {
+				return nil, fmt.Errorf("line %d: input %s comes after %s, which matches any word", line, input.name, grammar.inputs[len(grammar.inputs)-1].name)
+			}
+			grammar.inputs = append(grammar.inputs, input)
+
+		case "next":
+			nextLines = append(nextLines, nextLine{line, fields[1:]})
+
+		default:
+			return nil, fmt.Errorf("line %d: unknown directive %q", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(grammar.states) == 0:
+		return nil, fmt.Errorf("no states")
+	case len(grammar.inputs) == 0 || !grammar.inputs[len(grammar.inputs)-1].matchesAny():
+		return nil, fmt.Errorf("the last input must match any word")
+	case grammar.initial < 0:
+		return nil, fmt.Errorf("no initial state")
+	case grammar.accept < 0:
+		return nil, fmt.Errorf("no accept state")
+	case grammar.reject < 0:
+		return nil, fmt.Errorf("no reject state")
+	}
+
+	grammar.next = make([][]int, len(grammar.states))
+	for _, next := range nextLines {
+		from, ok := stateIndex[next.fields[0]]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown state %s", next.line, next.fields[0])
+		}
+		if grammar.next[from] != nil {
+			return nil, fmt.Errorf("line %d: state %s has two next lines", next.line, next.fields[0])
+		}
+		to := next.fields[1:]
+		if len(to) != len(grammar.inputs) {
+			return nil, fmt.Errorf("line %d: state %s has %d next states for %d inputs", next.line, next.fields[0], len(to), len(grammar.inputs))
+		}
+		grammar.next[from] = make([]int, len(to))
+		for input, name := range to {
+			if grammar.next[from][input], ok = stateIndex[name]; !ok {
+				return nil, fmt.Errorf("line %d: unknown state %s", next.line, name)
+			}
+		}
+	}
+	for state, next := range grammar.next {
+		if next == nil {
+			return nil, fmt.Errorf("state %s has no next line", grammar.states[state].name)
+		}
+	}
+
+	return grammar, nil
+}
+
+// Reads the grammar in the file at path
+func ReadGrammarFile(path string) (*NoticeGrammar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	grammar, err := ReadGrammar(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return grammar, nil
+}
+
+// Identifies the grammar, for caches of what was found with it
+func (grammar *NoticeGrammar) Signature() string {
+	hash := sha1.New()
+	for _, state := range grammar.states {
+		fmt.Fprintf(hash, "state %s %t %t %t\n", state.name, state.begin, state.final, state.anchored)
+	}
+	for _, input := range grammar.inputs {
+		fmt.Fprintf(hash, "input %s %q %q\n", input.name, input.word, input.tag)
+	}
+	for _, next := range grammar.next {
+		fmt.Fprintf(hash, "next %v\n", next)
+	}
+	fmt.Fprintf(hash, "%d %d %d\n", grammar.initial, grammar.accept, grammar.reject)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (input *grammarInput) matchesAny() bool {
+	return input.word == "" && input.tag == ""
+}
+
+// The copyright sign in Latin-1 is the grammar's ©
+func grammarWord(word string) string {
+	if word == "\xa9" {
+		return "©"
+	}
+	return word
+}
+
+// Returns the input of a word with a tag, the first that matches it
+func (grammar *NoticeGrammar) input(word string, tag string) int {
+	word = grammarWord(word)
+	for i := range grammar.inputs {
+		input := &grammar.inputs[i]
+		if (input.word == "" || strings.EqualFold(word, input.word)) && (input.tag == "" || input.tag == tag) {
+			return i
+		}
+	}
+	return len(grammar.inputs) - 1
+}
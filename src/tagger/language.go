@@ -0,0 +1,514 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file is about the copyright notices of languages other than
+// English.  The model is trained on an English corpus, so the words the
+// notice grammar starts from are English too; a language gives the words
+// it has for them, "Urheberrecht" for "copyright" and "Alle Rechte
+// vorbehalten" for "All rights reserved", and the tagger marks those in
+// the text as the English keyword they stand for, so that the grammar
+// reads them as it does the English, while Extract still returns the
+// words as written.  The notices the keywords are found in are in that
+// language.
+//
+// A language also has a lexicon of its own, of the suffixes its legal
+// entities are written with and such, which is added to that of the
+// tagger.
+//
+// The default languages are embedded in the package, see
+// ReadLanguages() for the format.
+
+package tagger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The English keywords the words of other languages are read as by the
+// notice grammar, see DefaultGrammar.txt
+const (
+	// a word for "copyright"
+	KeywordCopyright = "copyright"
+	// a whole phrase for "All rights reserved"
+	KeywordReserved = "allrightsreserved"
+)
+
+// The language of a notice without a keyword of another language in it
+const English = "en"
+
+// The languages that every Tagger starts with
+//
+//go:embed DefaultLanguages.txt
+var defaultLanguages []byte
+
+// A language other than English, see ReadLanguages()
+type Language struct {
+	// the ISO 639-1 code, such as "de", and the English name
+	Code string
+	Name string
+	// the words for "copyright" and the phrases for "All rights reserved"
+	Copyright []string
+	Reserved  []string
+	// the suffixes of legal entities and such
+	Lexicon *Lexicon
+}
+
+// The languages a tagger finds the notices of.  The prefilter of a tagger
+// must have their keywords among its patterns for the notices to be
+// found, see Patterns().
+type LanguageSet struct {
+	Languages []*Language
+
+	// the keywords split into words the way text is, longest first, by
+	// the first word in lower case
+	keywords map[string][]languageKeyword
+	// the first bytes of the first words, in any case, and their lengths,
+	// so that most words are passed over without looking them up
+	first    [256]bool
+	firstLen map[int]bool
+	// the one word keywords and the entities written in scripts without
+	// spaces, which are found inside words
+	inner         []languageKeyword
+	innerEntities []string
+}
+
+type languageKeyword struct {
+	// the words as written and as they are compared, normalized and in
+	// lower case
+	words   []string
+	folded  []string
+	keyword string
+	lang    string
+}
+
+// Returns the default languages, those of DefaultLanguages.txt
+func DefaultLanguages() *LanguageSet {
+	languages, err := ReadLanguages(bytes.NewReader(defaultLanguages))
+	if err != nil {
+		panic("tagger: DefaultLanguages.txt: " + err.Error())
+	}
+	return languages
+}
+
+// Reads languages from r.  Blank lines and lines starting with # are
+// skipped.  A line "language CODE NAME" starts a language, and the
+// lines after it are its entries in sections, as in a lexicon file:
+//
+//	language de German
+//	[copyright]
+//	Urheberrecht
+//	[reserved]
+//	Alle Rechte vorbehalten
+//	[entities]
+//	GmbH
+//
+// The [copyright] section holds the words for "copyright" and [reserved]
+// the phrases for "All rights reserved"; every language has at least one
+// of either.  The [entities], [organizations] and [abbreviations] sections
+// are the lexicon of the language, see Lexicon.Read().
+func ReadLanguages(r io.Reader) (*LanguageSet, error) {
+	languages := &LanguageSet{}
+	var language *Language
+	section := ""
+
+	line := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if fields := strings.Fields(entry); fields[0] == "language" {
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: expected \"language CODE NAME\"", line)
+			}
+			if languages.Language(fields[1]) != nil || fields[1] == English {
+				return nil, fmt.Errorf("line %d: language %s is defined twice", line, fields[1])
+			}
+			language = &Language{Code: fields[1], Name: strings.Join(fields[2:], " "), Lexicon: &Lexicon{}}
+			languages.Languages = append(languages.Languages, language)
+			section = ""
+			continue
+		}
+		if language == nil {
+			return nil, fmt.Errorf("line %d: %q is not in a language", line, entry)
+		}
+
+		if strings.HasPrefix(entry, "[") && strings.HasSuffix(entry, "]") {
+			section = strings.TrimSpace(entry[1 : len(entry)-1])
+			if section != "copyright" && section != "reserved" && !isLexiconSection(section) {
+				return nil, fmt.Errorf("line %d: unknown section %q", line, section)
+			}
+			continue
+		}
+
+		switch section {
+		case "":
+			return nil, fmt.Errorf("line %d: %q is not in a section", line, entry)
+		case "copyright":
+			language.Copyright = append(language.Copyright, entry)
+		case "reserved":
+			language.Reserved = append(language.Reserved, entry)
+		default:
+			if err := language.Lexicon.add(section, entry); err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, language := range languages.Languages {
+		if len(language.Copyright) == 0 && len(language.Reserved) == 0 {
+			return nil, fmt.Errorf("language %s has no keywords", language.Code)
+		}
+		language.Lexicon.index()
+	}
+	languages.index()
+	return languages, nil
+}
+
+// Returns the language with the code, nil if there is none
+func (languages *LanguageSet) Language(code string) *Language {
+	if languages == nil {
+		return nil
+	}
+	for _, language := range languages.Languages {
+		if language.Code == code {
+			return language
+		}
+	}
+	return nil
+}
+
+// Returns the English name of the language with the code
+func (languages *LanguageSet) Name(code string) string {
+	if code == English {
+		return "English"
+	}
+	if language := languages.Language(code); language != nil {
+		return language.Name
+	}
+	return code
+}
+
+// Returns the keywords of the languages, as the patterns of a Prefilter:
+// each as it is written and in lower and upper case, since the prefilter
+// only ignores the case of ASCII letters
+func (languages *LanguageSet) Patterns() []string {
+	if languages == nil {
+		return nil
+	}
+	var patterns []string
+	for _, language := range languages.Languages {
+		for _, entries := range [][]string{language.Copyright, language.Reserved} {
+			for _, entry := range entries {
+				patterns = append(patterns, entry)
+				for _, cased := range []string{strings.ToLower(entry), strings.ToUpper(entry)} {
+					if cased != entry && cased != patterns[len(patterns)-1] {
+						patterns = append(patterns, cased)
+					}
+				}
+			}
+		}
+	}
+	return patterns
+}
+
+// Adds the lexicons of the languages to lexicon
+func (languages *LanguageSet) addLexicons(lexicon *Lexicon) {
+	if languages == nil {
+		return
+	}
+	for _, language := range languages.Languages {
+		lexicon.Entities = append(lexicon.Entities, language.Lexicon.Entities...)
+		lexicon.Organizations = append(lexicon.Organizations, language.Lexicon.Organizations...)
+		lexicon.Abbreviations = append(lexicon.Abbreviations, language.Lexicon.Abbreviations...)
+	}
+	lexicon.index()
+}
+
+// Identifies the languages, for caches of what was found with them
+func (languages *LanguageSet) Signature() string {
+	if languages == nil {
+		return ""
+	}
+
+	hash := sha1.New()
+	for _, language := range languages.Languages {
+		fmt.Fprintf(hash, "language %s %s %q %q %s\n", language.Code, language.Name,
+			language.Copyright, language.Reserved, language.Lexicon.Signature())
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Builds the lookup tables of the keywords
+func (languages *LanguageSet) index() {
+	languages.keywords = make(map[string][]languageKeyword)
+	languages.firstLen = make(map[int]bool)
+	for _, language := range languages.Languages {
+		for _, section := range []struct {
+			keyword string
+			entries []string
+		}{
+			{KeywordCopyright, language.Copyright},
+			{KeywordReserved, language.Reserved},
+		} {
+			for _, entry := range section.entries {
+				keyword := languageKeyword{keyword: section.keyword, lang: language.Code}
+				for _, taggedWord := range mkWrdArray([]byte(entry), nil) {
+					if taggedWord.word != "" {
+						keyword.words = append(keyword.words, taggedWord.word)
+						keyword.folded = append(keyword.folded, foldWord(taggedWord.word))
+					}
+				}
+				if len(keyword.words) == 0 {
+					continue
+				}
+
+				key := keyword.folded[0]
+				languages.keywords[key] = append(languages.keywords[key], keyword)
+				for _, first := range []string{keyword.words[0], strings.ToLower(keyword.words[0]), strings.ToUpper(keyword.words[0])} {
+					languages.first[first[0]] = true
+					languages.firstLen[len(first)] = true
+				}
+				if len(keyword.words) == 1 && isUnspaced(entry) {
+					languages.inner = append(languages.inner, keyword)
+				}
+			}
+		}
+
+		for _, entity := range language.Lexicon.Entities {
+			if isUnspaced(entity) {
+				languages.innerEntities = append(languages.innerEntities, entity)
+			}
+		}
+	}
+
+	for _, keywords := range languages.keywords {
+		sort.SliceStable(keywords, func(i, j int) bool { return len(keywords[i].words) > len(keywords[j].words) })
+	}
+	// the longest first, so that 股份有限公司 is found rather than 有限公司
+	sort.SliceStable(languages.inner, func(i, j int) bool {
+		return len(languages.inner[i].words[0]) > len(languages.inner[j].words[0])
+	})
+}
+
+// returns true if any of the text is in a script written without spaces
+// between the words.  Those are all three bytes a rune in UTF-8, so only
+// the first byte of each rune is looked at; text in Latin and the other
+// alphabets, which is most of it, has none.
+func hasUnspaced(rawBytes []byte) bool {
+	for _, b := range rawBytes {
+		if b >= 0xe0 {
+			return true
+		}
+	}
+	return false
+}
+
+// returns true if the text is in one of the scripts written without
+// spaces between the words
+func isUnspaced(text string) bool {
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+// Splits the one word keywords of scripts without spaces out of the words
+// they are found inside, as in "华为技术有限公司版权所有", so that each is a
+// word of its own before the words are tagged.  Only called for text that
+// hasUnspaced().
+func (languages *LanguageSet) splitWords(wrdArry []TaggedWord) []TaggedWord {
+	if languages == nil || len(languages.inner) == 0 {
+		return wrdArry
+	}
+
+	var split []TaggedWord
+	for i, taggedWord := range wrdArry {
+		// the scripts without spaces are all three bytes a rune in UTF-8
+		if strings.IndexFunc(taggedWord.word, func(r rune) bool { return r >= 0x800 }) < 0 {
+			if split != nil {
+				split = append(split, taggedWord)
+			}
+			continue
+		}
+
+		parts := languages.splitWord(taggedWord)
+		if split == nil {
+			if len(parts) == 1 {
+				continue
+			}
+			split = append(make([]TaggedWord, 0, len(wrdArry)+2), wrdArry[:i]...)
+		}
+		split = append(split, parts...)
+	}
+	if split == nil {
+		return wrdArry
+	}
+	return split
+}
+
+// Splits the word around the longest one word keyword in it, and the rest
+// after it the same way
+func (languages *LanguageSet) splitWord(taggedWord TaggedWord) []TaggedWord {
+	word := taggedWord.word
+	for _, keyword := range languages.inner {
+		i := strings.Index(word, keyword.words[0])
+		if i < 0 || word == keyword.words[0] {
+			continue
+		}
+
+		var parts []TaggedWord
+		end := i + len(keyword.words[0])
+		if i > 0 {
+			parts = append(parts, TaggedWord{word: word[:i], byteStart: taggedWord.byteStart})
+		}
+		parts = append(parts, TaggedWord{word: word[i:end], byteStart: taggedWord.byteStart + i})
+		if end < len(word) {
+			parts = append(parts, languages.splitWord(TaggedWord{word: word[end:], byteStart: taggedWord.byteStart + end})...)
+		}
+		return parts
+	}
+	return []TaggedWord{taggedWord}
+}
+
+// Marks the keywords of the languages in the tagged words with the English
+// keyword they stand for, making one word of those of a phrase, and tags
+// the words with an entity written in a script without spaces inside them
+// as proper nouns, and their punctuation as the ASCII punctuation.
+// rawBytes is the text the words are of, and unspaced whether it
+// hasUnspaced(); if not there are no such words to look for.
+func (languages *LanguageSet) markWords(wrdArry []TaggedWord, rawBytes []byte, unspaced bool) []TaggedWord {
+	if languages == nil {
+		return wrdArry
+	}
+
+	// the words are folded in here to look them up
+	var folded [64]byte
+	marked := wrdArry[:0]
+	for i := 0; i < len(wrdArry); i++ {
+		taggedWord := wrdArry[i]
+		if taggedWord.word == "" {
+			marked = append(marked, taggedWord)
+			continue
+		}
+
+		if languages.first[taggedWord.word[0]] && languages.firstLen[len(taggedWord.word)] {
+			if keyword, n := languages.keywordAt(wrdArry[i:], folded[:0]); n != 0 {
+				last := wrdArry[i+n-1]
+				end := last.byteStart + len(last.word)
+				marked = append(marked, TaggedWord{word: string(rawBytes[taggedWord.byteStart:end]), tag: "nn",
+					byteStart: taggedWord.byteStart, keyword: keyword.keyword, lang: keyword.lang})
+				i += n - 1
+				continue
+			}
+		}
+		if unspaced && taggedWord.word[0] >= utf8.RuneSelf {
+			if tag, ok := fullWidthTags[taggedWord.word]; ok {
+				taggedWord.tag = tag
+			}
+			for _, entity := range languages.innerEntities {
+				if strings.Contains(taggedWord.word, entity) {
+					taggedWord.tag = "np"
+					break
+				}
+			}
+		}
+		marked = append(marked, taggedWord)
+	}
+	return marked
+}
+
+// The punctuation of the scripts without spaces, which the English corpus
+// doesn't have, tagged as the ASCII punctuation it stands for
+var fullWidthTags = map[string]string{
+	"。": ".", "．": ".", "，": ",", "、": ",", "：": ":", "（": "(", "）": ")",
+}
+
+// Returns the keyword the words start with, and how many of the words it
+// is, 0 if they don't start one.  The words are folded into buf.
+func (languages *LanguageSet) keywordAt(wrdArry []TaggedWord, buf []byte) (languageKeyword, int) {
+	buf = appendFolded(buf[:0], wrdArry[0].word)
+	for _, keyword := range languages.keywords[string(buf)] {
+		if len(wrdArry) < len(keyword.words) {
+			continue
+		}
+		n := 1
+		for n < len(keyword.words) {
+			buf = appendFolded(buf[:0], wrdArry[n].word)
+			if string(buf) != keyword.folded[n] {
+				break
+			}
+			n++
+		}
+		if n == len(keyword.words) {
+			return keyword, n
+		}
+	}
+	return languageKeyword{}, 0
+}
+
+// Returns the word the way keywords are compared: normalized, so that a
+// curly apostrophe is a straight one, and in lower case
+func foldWord(word string) string {
+	return strings.ToLower(normalizeWord(word))
+}
+
+// Appends the word to buf folded as foldWord() does it, without
+// allocating for a word in ASCII
+func appendFolded(buf []byte, word string) []byte {
+	start := len(buf)
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if c >= utf8.RuneSelf {
+			return append(buf[:start], foldWord(word)...)
+		}
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
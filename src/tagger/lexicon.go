@@ -0,0 +1,355 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file holds the lexicon of the words copyright holders are written
+// with that the corpus can't be relied on for: the suffixes of legal
+// entities ("Inc.", "GmbH", "S.A."), the names of well known
+// organizations, and abbreviations.  The tokenizer keeps an entry with a
+// period or other symbol in it as one word, the sentence splitter doesn't
+// end a sentence at the period that ends one, and the words of entities
+// and organizations are tagged as proper nouns whatever the model thinks.
+//
+// A default lexicon is embedded in the package; it can be extended with
+// more entries from a file in the same format, see Read().
+
+package tagger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The sections of a lexicon file
+const (
+	// suffixes of legal entities, one word each
+	LexiconEntities = "entities"
+	// names of organizations, any number of words
+	LexiconOrganizations = "organizations"
+	// abbreviations, one word each, that don't end a sentence
+	LexiconAbbreviations = "abbreviations"
+)
+
+// The lexicon that every Tagger starts with
+//
+//go:embed DefaultLexicon.txt
+var defaultLexicon []byte
+
+// The entries of a lexicon by section, see Read()
+type Lexicon struct {
+	Entities      []string
+	Organizations []string
+	Abbreviations []string
+
+	// the entities and abbreviations with a symbol in them, longest
+	// first, by their first byte in lower case
+	compounds map[byte][]string
+	// the words of each entity and organization, longest first, by the
+	// first word in lower case
+	names map[string][][]string
+}
+
+// Returns a new copy of the default lexicon, which can be extended
+// without changing that of any other Tagger
+func DefaultLexicon() *Lexicon {
+	lexicon := &Lexicon{}
+	if err := lexicon.Read(bytes.NewReader(defaultLexicon)); err != nil {
+		panic("tagger: DefaultLexicon.txt: " + err.Error())
+	}
+	return lexicon
+}
+
+// Adds the entries read from r to the lexicon.  Each line is an entry,
+// blank lines and lines starting with # are skipped, and a line such as
+// "[entities]" starts the section the entries after it go in:
+//
+//	[entities]
+//	GmbH
+//	S.A.
+//	[organizations]
+//	Free Software Foundation
+//	[abbreviations]
+//	e.g.
+//
+// Entries with a period in them match whatever their case, the others
+// only as they are written, so that "AB" isn't mistaken for "ab".
+func (lexicon *Lexicon) Read(r io.Reader) error {
+	defer lexicon.index()
+
+	section := ""
+	line := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(entry, "[") && strings.HasSuffix(entry, "]") {
+			section = strings.TrimSpace(entry[1 : len(entry)-1])
+			if !isLexiconSection(section) {
+				return fmt.Errorf("line %d: unknown section %q", line, section)
+			}
+			continue
+		}
+		if section == "" {
+			return fmt.Errorf("line %d: %q is not in a section", line, entry)
+		}
+		if err := lexicon.add(section, entry); err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Adds the entries of the lexicon file at path to the lexicon
+func (lexicon *Lexicon) ReadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lexicon.Read(f); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// Adds an entry to a section of the lexicon, one of LexiconEntities,
+// LexiconOrganizations or LexiconAbbreviations
+func (lexicon *Lexicon) Add(section string, entry string) error {
+	if !isLexiconSection(section) {
+		return fmt.Errorf("unknown section %q", section)
+	}
+	defer lexicon.index()
+	return lexicon.add(section, strings.TrimSpace(entry))
+}
+
+func (lexicon *Lexicon) add(section string, entry string) error {
+	if entry == "" {
+		return fmt.Errorf("empty entry")
+	}
+
+	switch section {
+	case LexiconEntities:
+		if len(strings.Fields(entry)) != 1 {
+			return fmt.Errorf("entity %q is more than one word", entry)
+		}
+		lexicon.Entities = append(lexicon.Entities, entry)
+	case LexiconOrganizations:
+		lexicon.Organizations = append(lexicon.Organizations, strings.Join(strings.Fields(entry), " "))
+	case LexiconAbbreviations:
+		if len(strings.Fields(entry)) != 1 {
+			return fmt.Errorf("abbreviation %q is more than one word", entry)
+		}
+		lexicon.Abbreviations = append(lexicon.Abbreviations, entry)
+	}
+	return nil
+}
+
+func isLexiconSection(section string) bool {
+	return section == LexiconEntities || section == LexiconOrganizations || section == LexiconAbbreviations
+}
+
+// Identifies the entries of the lexicon, for caches of what was found
+// with it
+func (lexicon *Lexicon) Signature() string {
+	if lexicon == nil {
+		return ""
+	}
+
+	hash := sha1.New()
+	for _, section := range []struct {
+		name    string
+		entries []string
+	}{
+		{LexiconEntities, lexicon.Entities},
+		{LexiconOrganizations, lexicon.Organizations},
+		{LexiconAbbreviations, lexicon.Abbreviations},
+	} {
+		for _, entry := range section.entries {
+			fmt.Fprintf(hash, "%s\x00%s\n", section.name, entry)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Builds the lookup tables of the tokenizer, the sentence splitter and
+// the tagger from the entries
+func (lexicon *Lexicon) index() {
+	lexicon.compounds = make(map[byte][]string)
+	for _, entries := range [][]string{lexicon.Entities, lexicon.Abbreviations} {
+		for _, entry := range entries {
+			if isCompound(entry) {
+				key := lowerByte(entry[0])
+				lexicon.compounds[key] = append(lexicon.compounds[key], entry)
+			}
+		}
+	}
+	for _, compounds := range lexicon.compounds {
+		sort.SliceStable(compounds, func(i, j int) bool { return len(compounds[i]) > len(compounds[j]) })
+	}
+
+	// the names are split into words the way text is, compounds and all
+	lexicon.names = make(map[string][][]string)
+	for _, entries := range [][]string{lexicon.Entities, lexicon.Organizations} {
+		for _, entry := range entries {
+			var name []string
+			for _, taggedWord := range mkWrdArray([]byte(entry), lexicon) {
+				if taggedWord.word != "" {
+					name = append(name, taggedWord.word)
+				}
+			}
+			if len(name) == 0 {
+				continue
+			}
+			key := strings.ToLower(name[0])
+			lexicon.names[key] = append(lexicon.names[key], name)
+		}
+	}
+	for _, names := range lexicon.names {
+		sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	}
+}
+
+// returns true if the entry has a symbol in it that the tokenizer would
+// otherwise split it at
+func isCompound(entry string) bool {
+	for i := 0; i < len(entry); {
+		r, size := decodeRune([]byte(entry[i:]))
+		if isWordSymbol(r, size) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+func lowerByte(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// returns true if the word is the entry, see Read() for when case matters
+func matchesEntry(word string, entry string) bool {
+	return word == entry || strings.Contains(entry, ".") && strings.EqualFold(word, entry)
+}
+
+// Given the bytes at the start of a word returns the length of the
+// compound entry they start, or 0 if they don't start one
+func (lexicon *Lexicon) compoundLen(rawBytes []byte) int {
+	if lexicon == nil || len(rawBytes) == 0 {
+		return 0
+	}
+
+	for _, compound := range lexicon.compounds[lowerByte(rawBytes[0])] {
+		if len(rawBytes) >= len(compound) && matchesEntry(string(rawBytes[:len(compound)]), compound) &&
+			!continuesWord(rawBytes[len(compound):]) {
+			return len(compound)
+		}
+	}
+	return 0
+}
+
+// returns true if the text ends with a compound entry, so that the period
+// it ends with is not the end of a sentence
+func (lexicon *Lexicon) endsWithCompound(text []byte) bool {
+	if lexicon == nil {
+		return false
+	}
+
+	for _, compounds := range lexicon.compounds {
+		for _, compound := range compounds {
+			start := len(text) - len(compound)
+			if start >= 0 && matchesEntry(string(text[start:]), compound) && !endsWithWord(text[:start]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// returns true if the last rune of rawBytes is part of a word, the
+// opposite of continuesWord for the rune before a word
+func endsWithWord(rawBytes []byte) bool {
+	if len(rawBytes) == 0 {
+		return false
+	}
+	r, size := utf8.DecodeLastRune(rawBytes)
+	return !isSpace(r) && !isWordSymbol(r, size)
+}
+
+// Tags the words of the entities and organizations in wrdArry as proper
+// nouns, the longest name first, leaving the model to tag the rest
+func (lexicon *Lexicon) tagWords(wrdArry []TaggedWord) {
+	if lexicon == nil {
+		return
+	}
+
+	for i := 0; i < len(wrdArry); i++ {
+		for _, name := range lexicon.names[strings.ToLower(wrdArry[i].word)] {
+			if !startsName(wrdArry[i:], name) {
+				continue
+			}
+			for j, word := range name {
+				// the commas and such of a name are left to the model
+				if r, size := utf8.DecodeRuneInString(word); size != len(word) || !isWordSymbol(r, size) {
+					wrdArry[i+j].tag = "np"
+				}
+			}
+			i += len(name) - 1
+			break
+		}
+	}
+}
+
+// returns true if the words start with the words of the name
+func startsName(wrdArry []TaggedWord, name []string) bool {
+	if len(wrdArry) < len(name) {
+		return false
+	}
+	for i, word := range name {
+		if !matchesEntry(wrdArry[i].word, word) {
+			return false
+		}
+	}
+	return true
+}
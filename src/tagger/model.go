@@ -0,0 +1,510 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file saves and loads trained tagger models.  Training from the
+// text corpus means splitting and counting the whole corpus on every run,
+// so a model can instead be written out once in a compact binary form
+// (for the hidden Markov models the dictionary, the transition matrix and
+// the unknown word model, for the perceptron its weights) and loaded
+// quickly.
+//
+// A default model is embedded in the package so that a Tagger can be
+// had without any corpus at all, see Default().
+
+package tagger
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// The model file starts with these bytes followed by the format version
+const modelMagic = "TGMD"
+const modelVersion uint32 = 3
+
+// The default model, made from DefaultCorpus.in with "make model"
+//
+//go:embed default.model
+var defaultModel []byte
+
+var errBadModel = errors.New("not a tagger model")
+
+// Returns a Tagger using the model embedded in the package
+func Default() (*Tagger, error) {
+	return LoadModel(bytes.NewReader(defaultModel))
+}
+
+// Returns a Tagger using the model saved in the file at path
+func NewFromModel(path string) (*Tagger, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	copyrightTagger, err := LoadModel(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return copyrightTagger, nil
+}
+
+// Writes the model of the tagger to w.  The layout is, all integers
+// little endian and all strings prefixed with their uvarint length:
+//
+//	magic, version, signature, kind of model
+//	number of tags, then each tag name in index order: the TagSet of
+//	the model, which every tag index after it is into
+//	the model itself, as written by the save method of its kind
+//
+// Version 1 files have no kind; they are all bigram models.  Version 2
+// hidden Markov models have no unknown word model.
+func (copyrightTagger *Tagger) SaveModel(w io.Writer) error {
+	tags := copyrightTagger.Model.Tags()
+	mw := &modelWriter{w: bufio.NewWriter(w), tags: tags}
+
+	mw.bytes([]byte(modelMagic))
+	mw.uint32(modelVersion)
+	mw.string(copyrightTagger.Signature)
+	mw.string(copyrightTagger.Model.Kind())
+
+	mw.uint32(uint32(tags.Len()))
+	for tag := 0; tag < tags.Len(); tag++ {
+		mw.string(tags.Name(tag))
+	}
+
+	copyrightTagger.Model.save(mw)
+
+	if mw.err != nil {
+		return mw.err
+	}
+	return mw.w.Flush()
+}
+
+// Reads a model written by SaveModel and returns a Tagger using it.  The
+// whole model is read first, so that no count in it is believed that
+// there aren't the bytes left for.
+func LoadModel(r io.Reader) (*Tagger, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	mr := &modelReader{r: bytes.NewReader(data)}
+
+	magic := mr.bytes(len(modelMagic))
+	if mr.err != nil || string(magic) != modelMagic {
+		return nil, errBadModel
+	}
+	version := mr.uint32()
+	if mr.err == nil && (version < 1 || version > modelVersion) {
+		return nil, fmt.Errorf("unsupported model version %d", version)
+	}
+	mr.version = version
+	signature := mr.string()
+	kind := "bigram"
+	if mr.version >= 2 {
+		kind = mr.string()
+	}
+
+	ntags := mr.uint32()
+	if mr.err == nil && ntags > maxTags {
+		return nil, fmt.Errorf("model has %d tags, at most %d can be used", ntags, maxTags)
+	}
+	var names []string
+	for tag := 0; tag < mr.count(uint64(ntags), 1) && mr.err == nil; tag++ {
+		names = append(names, mr.string())
+	}
+
+	var model POSTagger
+	if mr.err == nil {
+		tags, err := NewTagSet(names)
+		if err != nil {
+			return nil, fmt.Errorf("model tags: %s", err)
+		}
+		mr.tags = tags
+
+		k, err := findKind(kind)
+		if err != nil {
+			return nil, err
+		}
+		model, err = k.load(mr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if mr.err != nil {
+		if mr.err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, mr.err
+	}
+
+	return newTagger(model, signature), nil
+}
+
+// The unigram dictionary: number of words, then for each word in sorted
+// order the word, its number of tags, then (tag index, frequency) pairs
+func (mw *modelWriter) dictionary(dictionary map[string][]TagFrequency) {
+	words := make([]string, 0, len(dictionary))
+	for word := range dictionary {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	mw.uint32(uint32(len(words)))
+	for _, word := range words {
+		mw.string(word)
+		mw.uvarint(uint64(len(dictionary[word])))
+		for _, tagObject := range dictionary[word] {
+			tag, _ := mw.tags.Index(tagObject.tag)
+			mw.uvarint(uint64(tag))
+			mw.float32(tagObject.freq)
+		}
+	}
+}
+
+func (mr *modelReader) dictionary() map[string][]TagFrequency {
+	nwords := mr.count(uint64(mr.uint32()), 2)
+	var dictionary = make(map[string][]TagFrequency)
+	for i := 0; i < nwords && mr.err == nil; i++ {
+		word := mr.string()
+		n := mr.count(mr.uvarint(), 5)
+		for j := 0; j < n && mr.err == nil; j++ {
+			tag := mr.tag()
+			freq := mr.float32()
+			if mr.err == nil {
+				dictionary[word] = append(dictionary[word], TagFrequency{mr.tags.Name(tag), freq})
+			}
+		}
+	}
+	return dictionary
+}
+
+// Per key weights or counts for each tag: number of keys, then for each
+// key in sorted order the key, its number of non-zero values, then (tag
+// index, value) pairs
+func (mw *modelWriter) tagValues(values map[string][]float32) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mw.uint32(uint32(len(keys)))
+	for _, key := range keys {
+		nonzero := 0
+		for _, value := range values[key] {
+			if value != 0 {
+				nonzero++
+			}
+		}
+
+		mw.string(key)
+		mw.uvarint(uint64(nonzero))
+		for tag, value := range values[key] {
+			if value != 0 {
+				mw.uvarint(uint64(tag))
+				mw.float32(value)
+			}
+		}
+	}
+}
+
+func (mr *modelReader) tagValues() map[string][]float32 {
+	nkeys := mr.count(uint64(mr.uint32()), 2)
+	var values = make(map[string][]float32)
+	for i := 0; i < nkeys && mr.err == nil; i++ {
+		key := mr.string()
+		n := mr.count(mr.uvarint(), 5)
+		tagValues := make([]float32, mr.tags.Len())
+		for j := 0; j < n && mr.err == nil; j++ {
+			tag := mr.tag()
+			value := mr.float32()
+			if mr.err == nil {
+				tagValues[tag] = value
+			}
+		}
+		values[key] = tagValues
+	}
+	return values
+}
+
+// The unknown word model: 0 if there is none, else 1 followed by the
+// options (the longest suffix, the longest prefix, the most times a word
+// learned from was seen and whether shapes are used, all uvarints, then
+// the smoothing as float32), the
+// count of each tag as float32, then the counts by suffix, by prefix and
+// by shape.  The probabilities are worked out again from the counts.
+func (mw *modelWriter) unknown(unknown *UnknownModel) {
+	if unknown == nil {
+		mw.uvarint(0)
+		return
+	}
+	mw.uvarint(1)
+
+	options := unknown.Options
+	shapes := uint64(0)
+	if options.Shapes {
+		shapes = 1
+	}
+	mw.uvarint(uint64(options.MaxSuffix))
+	mw.uvarint(uint64(options.MaxPrefix))
+	mw.uvarint(uint64(options.MaxFreq))
+	mw.uvarint(shapes)
+	mw.float32(float32(options.Smoothing))
+
+	for _, count := range unknown.Tags {
+		mw.float32(count)
+	}
+	mw.tagValues(unknown.Suffixes)
+	mw.tagValues(unknown.Prefixes)
+	mw.tagValues(unknown.Shapes)
+}
+
+func (mr *modelReader) unknown() *UnknownModel {
+	if mr.version < 3 || mr.uvarint() == 0 || mr.err != nil {
+		return nil
+	}
+
+	var options UnknownOptions
+	options.MaxSuffix = int(mr.uvarint())
+	options.MaxPrefix = int(mr.uvarint())
+	options.MaxFreq = int(mr.uvarint())
+	options.Shapes = mr.uvarint() != 0
+	options.Smoothing = float64(mr.float32())
+
+	unknown := &UnknownModel{Options: options, Tags: make([]float32, mr.count(uint64(mr.tags.Len()), 4))}
+	for tag := range unknown.Tags {
+		unknown.Tags[tag] = mr.float32()
+	}
+	unknown.Suffixes = mr.tagValues()
+	unknown.Prefixes = mr.tagValues()
+	unknown.Shapes = mr.tagValues()
+	if mr.err != nil {
+		return nil
+	}
+	mr.err = unknown.check(mr.tags)
+	if mr.err != nil {
+		return nil
+	}
+
+	unknown.estimate(mr.tags)
+	return unknown
+}
+
+// bigram: the transition matrix, row by row, as float32, the dictionary,
+// then the unknown word model
+func (bigram *Bigram) save(mw *modelWriter) {
+	for _, row := range bigram.TransMatrix {
+		for _, prob := range row {
+			mw.float32(prob)
+		}
+	}
+	mw.dictionary(bigram.Dictionary)
+	mw.unknown(bigram.Unknown)
+}
+
+func loadBigram(mr *modelReader) (POSTagger, error) {
+	if mr.count(uint64(mr.tags.Len()*mr.tags.Len()), 4) == 0 {
+		return nil, mr.err
+	}
+	var transMatrix = make([][]float32, mr.tags.Len())
+	for row := range transMatrix {
+		transMatrix[row] = make([]float32, mr.tags.Len())
+		for col := range transMatrix[row] {
+			transMatrix[row][col] = mr.float32()
+		}
+	}
+	dictionary := mr.dictionary()
+	unknown := mr.unknown()
+
+	return newBigram(mr.tags, dictionary, transMatrix, unknown), nil
+}
+
+// trigram: the three interpolation weights, the transition matrix
+// TransMatrix[t1][t2][t3] in index order, as float32, the dictionary, then
+// the unknown word model
+func (trigram *Trigram) save(mw *modelWriter) {
+	for _, lambda := range trigram.Lambda {
+		mw.float32(lambda)
+	}
+	for t1 := range trigram.TransMatrix {
+		for t2 := range trigram.TransMatrix[t1] {
+			for _, prob := range trigram.TransMatrix[t1][t2] {
+				mw.float32(prob)
+			}
+		}
+	}
+	mw.dictionary(trigram.Dictionary)
+	mw.unknown(trigram.Unknown)
+}
+
+func loadTrigram(mr *modelReader) (POSTagger, error) {
+	var lambda [3]float32
+	for i := range lambda {
+		lambda[i] = mr.float32()
+	}
+	if mr.count(uint64(mr.tags.Len()*mr.tags.Len()*mr.tags.Len()), 4) == 0 {
+		return nil, mr.err
+	}
+	var transMatrix = make([][][]float32, mr.tags.Len())
+	for t1 := range transMatrix {
+		transMatrix[t1] = make([][]float32, mr.tags.Len())
+		for t2 := range transMatrix[t1] {
+			transMatrix[t1][t2] = make([]float32, mr.tags.Len())
+			for t3 := range transMatrix[t1][t2] {
+				transMatrix[t1][t2][t3] = mr.float32()
+			}
+		}
+	}
+	dictionary := mr.dictionary()
+	unknown := mr.unknown()
+
+	return newTrigram(mr.tags, dictionary, transMatrix, lambda, unknown), nil
+}
+
+// perceptron: the weights of the features, as tag values
+func (perceptron *Perceptron) save(mw *modelWriter) {
+	mw.tagValues(perceptron.Weights)
+}
+
+func loadPerceptron(mr *modelReader) (POSTagger, error) {
+	return &Perceptron{Weights: mr.tagValues(), tags: mr.tags}, nil
+}
+
+// Helpers which remember the first error, so that the model can be
+// written and read field by field with a single check at the end
+
+type modelWriter struct {
+	w   *bufio.Writer
+	err error
+	// the tags of the model being written
+	tags *TagSet
+	buf  [binary.MaxVarintLen64]byte
+}
+
+func (mw *modelWriter) bytes(b []byte) {
+	if mw.err == nil {
+		_, mw.err = mw.w.Write(b)
+	}
+}
+
+func (mw *modelWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(mw.buf[:], v)
+	mw.bytes(mw.buf[:n])
+}
+
+func (mw *modelWriter) uint32(v uint32) {
+	binary.LittleEndian.PutUint32(mw.buf[:4], v)
+	mw.bytes(mw.buf[:4])
+}
+
+func (mw *modelWriter) float32(v float32) {
+	mw.uint32(math.Float32bits(v))
+}
+
+func (mw *modelWriter) string(s string) {
+	mw.uvarint(uint64(len(s)))
+	mw.bytes([]byte(s))
+}
+
+type modelReader struct {
+	r   *bytes.Reader
+	err error
+	// the format version of the file being read
+	version uint32
+	// the tags of the model being read
+	tags *TagSet
+}
+
+func (mr *modelReader) bytes(n int) []byte {
+	n = mr.count(uint64(n), 1)
+	if mr.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	_, mr.err = io.ReadFull(mr.r, b)
+	return b
+}
+
+func (mr *modelReader) uvarint() uint64 {
+	if mr.err != nil {
+		return 0
+	}
+	var v uint64
+	v, mr.err = binary.ReadUvarint(mr.r)
+	return v
+}
+
+func (mr *modelReader) uint32() uint32 {
+	b := mr.bytes(4)
+	if mr.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (mr *modelReader) float32() float32 {
+	return math.Float32frombits(mr.uint32())
+}
+
+func (mr *modelReader) string() string {
+	return string(mr.bytes(mr.count(mr.uvarint(), 1)))
+}
+
+// Returns n, a count of things of at least size bytes each, if there are
+// the bytes left for them, else 0 with an error
+func (mr *modelReader) count(n uint64, size int) int {
+	if mr.err != nil {
+		return 0
+	}
+	if n > uint64(mr.r.Len()/size) {
+		mr.err = fmt.Errorf("model is cut short or corrupt: %d things of %d bytes with %d bytes left", n, size, mr.r.Len())
+		return 0
+	}
+	return int(n)
+}
+
+// a tag index, which must be one of the model's tags
+func (mr *modelReader) tag() int {
+	tag := mr.uvarint()
+	if mr.err == nil && tag >= uint64(mr.tags.Len()) {
+		mr.err = fmt.Errorf("bad tag %d", tag)
+	}
+	return int(tag)
+}
@@ -0,0 +1,305 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file is the averaged perceptron tagger (Collins, Discriminative
+// Training Methods for Hidden Markov Models, 2002).  Rather than counting
+// probabilities it learns a weight for each tag from features of the word
+// and its neighbours: the word itself, its prefix and suffixes, its shape
+// ("Xxxx" for "Müller", "XxX" for "GmbH", "d" for "2015") and the tags
+// given to the words before it.  Shape and affix features let it tag words
+// it has never seen far better than a dictionary can.  Words are tagged
+// greedily from left to right.
+
+package tagger
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"unicode"
+)
+
+type Perceptron struct {
+	// the weight of each feature for each tag, averaged over training
+	Weights map[string][]float32
+
+	tags *TagSet
+}
+
+// how many times training goes over the corpus
+const perceptronIterations = 10
+
+// the tags before the start of a sentence
+const (
+	perceptronStart  = "-START-"
+	perceptronStart2 = "-START2-"
+)
+
+// The shape of a word: runs of upper case, lower case and digits become
+// X, x and d; anything else is kept as it is
+func wordShape(word string) string {
+	var shape []rune
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			r = 'X'
+		case unicode.IsLetter(r):
+			r = 'x'
+		case unicode.IsDigit(r):
+			r = 'd'
+		}
+		if len(shape) == 0 || shape[len(shape)-1] != r {
+			shape = append(shape, r)
+		}
+	}
+	return string(shape)
+}
+
+// the last n runes of word
+func suffix(word string, n int) string {
+	runes := []rune(word)
+	if len(runes) > n {
+		runes = runes[len(runes)-n:]
+	}
+	return string(runes)
+}
+
+// the words of a sentence as the features see them: normalized and in
+// lower case
+func perceptronContext(wrdArry []TaggedWord) []string {
+	context := make([]string, len(wrdArry))
+	for i := range wrdArry {
+		context[i] = strings.ToLower(wordNormalizer.Replace(wrdArry[i].word))
+	}
+	return context
+}
+
+// The features of the word at index i of a sentence, given the tags of
+// the two words before it
+func perceptronFeatures(wrdArry []TaggedWord, context []string, i int, prev string, prev2 string) []string {
+	word := context[i]
+	at := func(j int) string {
+		switch {
+		case j < 0:
+			return perceptronStart
+		case j >= len(context):
+			return "-END-"
+		}
+		return context[j]
+	}
+
+	firstRune := ""
+	for _, r := range word {
+		firstRune = string(r)
+		break
+	}
+
+	return []string{
+		"bias",
+		"w " + word,
+		"s3 " + suffix(word, 3),
+		"s2 " + suffix(word, 2),
+		"s1 " + suffix(word, 1),
+		"p1 " + firstRune,
+		"sh " + wordShape(wrdArry[i].word),
+		"t1 " + prev,
+		"t2 " + prev2 + " " + prev,
+		"t1w " + prev + " " + word,
+		"w-1 " + at(i-1),
+		"s-1 " + suffix(at(i-1), 3),
+		"w-2 " + at(i-2),
+		"w+1 " + at(i+1),
+		"s+1 " + suffix(at(i+1), 3),
+		"w+2 " + at(i+2),
+	}
+}
+
+// Returns the tag with the highest score for the features; bos is never
+// given to a word.  scores is where they are added up, one per tag.
+func (perceptron *Perceptron) predict(features []string, scores []float32) int {
+	for tag := range scores {
+		scores[tag] = 0
+	}
+	for _, feature := range features {
+		weights, ok := perceptron.Weights[feature]
+		if !ok {
+			continue
+		}
+		for tag, weight := range weights {
+			scores[tag] += weight
+		}
+	}
+
+	bos := perceptron.tags.bos
+	best := -1
+	for tag := range scores {
+		if tag != bos && (best < 0 || scores[tag] > scores[best]) {
+			best = tag
+		}
+	}
+	return best
+}
+
+// Returns how probable the best tag is by the scores of the tags, taking
+// them as log probabilities
+func (perceptron *Perceptron) tagProb(scores []float32, best int) float32 {
+	var total float64
+	for tag, score := range scores {
+		if tag != perceptron.tags.bos {
+			total += math.Exp(float64(score - scores[best]))
+		}
+	}
+	return float32(1 / total)
+}
+
+func (perceptron *Perceptron) Kind() string {
+	return "perceptron"
+}
+
+func (perceptron *Perceptron) Tags() *TagSet {
+	return perceptron.tags
+}
+
+func (perceptron *Perceptron) TagWords(wrdArry []TaggedWord) {
+	perceptron.tagWords(wrdArry, false)
+}
+
+func (perceptron *Perceptron) tagWords(wrdArry []TaggedWord, probs bool) {
+	context := perceptronContext(wrdArry)
+	scores := make([]float32, perceptron.tags.Len())
+
+	prev, prev2 := perceptronStart, perceptronStart2
+	for i := range wrdArry {
+		tag := wrdArry[i].tag
+		if _, ok := perceptron.tags.Index(tag); !ok {
+			best := perceptron.predict(perceptronFeatures(wrdArry, context, i, prev, prev2), scores)
+			tag = perceptron.tags.Name(best)
+			wrdArry[i].tag = tag
+			if probs {
+				wrdArry[i].tagProb = perceptron.tagProb(scores, best)
+			}
+		} else if probs {
+			wrdArry[i].tagProb = 1
+		}
+		prev2, prev = prev, tag
+	}
+}
+
+// Training keeps, for every weight, the sum of its values over all the
+// updates (totals) brought up to date lazily from when it last changed
+// (stamps), so that the average can be had at the end
+type perceptronTrainer struct {
+	numOfTags int
+	weights   map[string][]float32
+	totals    map[string][]float64
+	stamps    map[string][]int
+	instances int
+}
+
+func (trainer *perceptronTrainer) change(feature string, tag int, value float32) {
+	weights, ok := trainer.weights[feature]
+	if !ok {
+		weights = make([]float32, trainer.numOfTags)
+		trainer.weights[feature] = weights
+		trainer.totals[feature] = make([]float64, trainer.numOfTags)
+		trainer.stamps[feature] = make([]int, trainer.numOfTags)
+	}
+
+	trainer.totals[feature][tag] += float64(trainer.instances-trainer.stamps[feature][tag]) * float64(weights[tag])
+	trainer.stamps[feature][tag] = trainer.instances
+	weights[tag] += value
+}
+
+func (trainer *perceptronTrainer) update(truth int, guess int, features []string) {
+	trainer.instances++
+	if truth == guess {
+		return
+	}
+	for _, feature := range features {
+		trainer.change(feature, truth, 1)
+		trainer.change(feature, guess, -1)
+	}
+}
+
+// Returns the weights averaged over every update made in training
+func (trainer *perceptronTrainer) average() map[string][]float32 {
+	averaged := make(map[string][]float32, len(trainer.weights))
+
+	for feature, weights := range trainer.weights {
+		avg := make([]float32, trainer.numOfTags)
+		nonzero := false
+		for tag := range weights {
+			total := trainer.totals[feature][tag] + float64(trainer.instances-trainer.stamps[feature][tag])*float64(weights[tag])
+			avg[tag] = float32(total / float64(trainer.instances))
+			nonzero = nonzero || avg[tag] != 0
+		}
+		if nonzero {
+			averaged[feature] = avg
+		}
+	}
+
+	return averaged
+}
+
+// Goes over the sentences of the corpus several times, in a different
+// (but repeatable) order each time, tagging each one with the weights
+// learned so far and correcting them where the tags are wrong
+func trainPerceptron(corpus []TaggedWord, options TrainOptions) POSTagger {
+	tags := options.Tags
+	trainer := &perceptronTrainer{
+		numOfTags: tags.Len(),
+		weights:   make(map[string][]float32),
+		totals:    make(map[string][]float64),
+		stamps:    make(map[string][]int),
+	}
+	learning := &Perceptron{Weights: trainer.weights, tags: tags}
+	scores := make([]float32, tags.Len())
+
+	sentences := splitSentences(corpus)
+	shuffle := rand.New(rand.NewSource(1))
+
+	for iteration := 0; iteration < perceptronIterations; iteration++ {
+		for _, sentence := range sentences {
+			context := perceptronContext(sentence)
+
+			prev, prev2 := perceptronStart, perceptronStart2
+			for i := range sentence {
+				features := perceptronFeatures(sentence, context, i, prev, prev2)
+				guess := learning.predict(features, scores)
+				truth, _ := tags.Index(sentence[i].tag)
+				trainer.update(truth, guess, features)
+				prev2, prev = prev, tags.Name(guess)
+			}
+		}
+		shuffle.Shuffle(len(sentences), func(i, j int) {
+			sentences[i], sentences[j] = sentences[j], sentences[i]
+		})
+	}
+
+	return &Perceptron{Weights: trainer.average(), tags: tags}
+}
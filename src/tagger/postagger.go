@@ -0,0 +1,168 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file separates part of speech tagging from copyright detection.
+// A POSTagger is any model that gives the words of a sentence their tags;
+// the copyright DFA only ever looks at the tags.  There are several kinds
+// of model to choose from, trading speed for accuracy, and any of them can
+// be trained from a corpus, saved and loaded.
+
+package tagger
+
+import (
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// A part of speech tagging model.  A model never changes once it is made,
+// so it can tag in several goroutines at once.
+type POSTagger interface {
+	// the kind of model, one of Kinds()
+	Kind() string
+	// the tags the model tags with, which it was trained with
+	Tags() *TagSet
+	// sets the tag of each of the words of a sentence, but for the words
+	// that have one already
+	TagWords(wrdArry []TaggedWord)
+	// TagWords, and with probs how probable it found each tag too, see
+	// TaggedWord.tagProb
+	tagWords(wrdArry []TaggedWord, probs bool)
+
+	// writes the model, for the load function of its kind to read back
+	save(mw *modelWriter)
+}
+
+// The kind of model that is embedded in the package, see Default()
+const DefaultKind = "bigram"
+
+type modelKind struct {
+	name  string
+	about string
+	train func(corpus []TaggedWord, options TrainOptions) POSTagger
+	load  func(mr *modelReader) (POSTagger, error)
+}
+
+var modelKinds = []modelKind{
+	{"bigram", "bigram hidden Markov model, the fastest", trainBigram, loadBigram},
+	{"trigram", "trigram hidden Markov model with interpolated transitions", trainTrigram, loadTrigram},
+	{"perceptron", "averaged perceptron using word shape and context features", trainPerceptron, loadPerceptron},
+}
+
+// How a model is trained
+type TrainOptions struct {
+	// the kind of model, one of Kinds()
+	Kind string
+	// how the hidden Markov models learn to tag words not in their
+	// dictionary; the perceptron has features of its own for them
+	Unknown UnknownOptions
+	// the tags the model tags with, every tag of the corpus must be one;
+	// nil is DefaultTagSet()
+	Tags *TagSet
+}
+
+// Returns the default options for training a model of the given kind
+func NewTrainOptions(kind string) TrainOptions {
+	return TrainOptions{Kind: kind, Unknown: DefaultUnknownOptions, Tags: DefaultTagSet()}
+}
+
+// Returns the names of the kinds of model there are
+func Kinds() []string {
+	var names []string
+	for _, k := range modelKinds {
+		names = append(names, k.name)
+	}
+	return names
+}
+
+// Returns a description of the kind of model with the given name
+func AboutKind(name string) string {
+	k, err := findKind(name)
+	if err != nil {
+		return ""
+	}
+	return k.about
+}
+
+func findKind(name string) (*modelKind, error) {
+	for i := range modelKinds {
+		if modelKinds[i].name == name {
+			return &modelKinds[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown kind of tagger model %q (expected one of %s)",
+		name, strings.Join(Kinds(), ", "))
+}
+
+// Trains a model from the words of a corpus and their tags.  signature
+// identifies the corpus, and is prefixed with the kind of model.
+func train(corpus []TaggedWord, options TrainOptions, signature string) (*Tagger, error) {
+	k, err := findKind(options.Kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Tags == nil {
+		options.Tags = DefaultTagSet()
+	}
+	for _, taggedWord := range corpus {
+		if _, ok := options.Tags.corpusTag(taggedWord.tag); !ok {
+			return nil, fmt.Errorf("the tag %q of %q is not in the tag set", taggedWord.tag, taggedWord.word)
+		}
+	}
+
+	if signature != "" {
+		signature = options.Kind + "-" + signature
+	}
+
+	return newTagger(k.train(corpus, options), signature), nil
+}
+
+// The corpus the default model is made from
+//
+//go:embed DefaultCorpus.in
+var defaultCorpus []byte
+
+// Returns a Tagger with a model of the given kind made from the default
+// corpus.  The default kind is loaded from the embedded model, other
+// kinds are trained when asked for, which takes a moment.
+func NewDefault(kind string) (*Tagger, error) {
+	if kind == DefaultKind {
+		return Default()
+	}
+
+	corpus, err := ReadCorpus(defaultCorpus, FormatNative)
+	if err != nil {
+		return nil, err
+	}
+	signature := sha1.Sum(defaultCorpus)
+
+	return train(corpus, NewTrainOptions(kind), hex.EncodeToString(signature[:]))
+}
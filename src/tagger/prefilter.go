@@ -0,0 +1,340 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+// This file is the prefilter: a search for every word a copyright notice
+// could start with ("copyright", "(c)", "©" and the like) in a single
+// pass over the text, by the Aho-Corasick algorithm, so that the tagger
+// only tags the text near them rather than all of it.  Most of a source
+// tree is code and license text with no notice in it at all.
+//
+// A default set of patterns is embedded in the package; others can be
+// read from a file in the same format, see ReadPrefilter().
+
+package tagger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The patterns of the prefilter every Tagger starts with
+//
+//go:embed DefaultPrefilter.txt
+var defaultPrefilter []byte
+
+// How much text around a hit is tagged with it, by default
+const (
+	DefaultPrefilterBefore = 256
+	DefaultPrefilterAfter  = 1024
+)
+
+// The patterns a notice must have one of to be found, and how much text
+// around each hit to tag.  ASCII letters in the patterns match whatever
+// their case.  A prefilter never changes once made, so it can be shared.
+type Prefilter struct {
+	Patterns []string
+	// bytes of text before and after a hit that are tagged with it; the
+	// text tagged starts at the start of a line and ends at the end of
+	// one when there is one that near
+	Before int
+	After  int
+
+	// the Aho-Corasick automaton: the state after each byte from each
+	// state, and the length of the longest pattern that ends in a state
+	next [][256]int32
+	out  []int
+}
+
+// Returns the default prefilter, from DefaultPrefilter.txt and the
+// keywords of the DefaultLanguages()
+func DefaultPrefilter() *Prefilter {
+	prefilter, err := ReadPrefilter(bytes.NewReader(defaultPrefilter))
+	if err != nil {
+		panic("tagger: DefaultPrefilter.txt: " + err.Error())
+	}
+
+	withLanguages, err := NewPrefilter(append(prefilter.Patterns, DefaultLanguages().Patterns()...))
+	if err != nil {
+		panic("tagger: DefaultLanguages.txt: " + err.Error())
+	}
+	withLanguages.Before, withLanguages.After = prefilter.Before, prefilter.After
+	return withLanguages
+}
+
+// Returns a prefilter for the patterns that tags DefaultPrefilterBefore
+// and DefaultPrefilterAfter bytes around each hit
+func NewPrefilter(patterns []string) (*Prefilter, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no patterns")
+	}
+	prefilter := &Prefilter{Before: DefaultPrefilterBefore, After: DefaultPrefilterAfter}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return nil, fmt.Errorf("empty pattern")
+		}
+		prefilter.Patterns = append(prefilter.Patterns, pattern)
+	}
+	prefilter.compile()
+	return prefilter, nil
+}
+
+// Returns the prefilter of the patterns read from r, one to a line with
+// the space around it trimmed.  Blank lines and lines starting with # are
+// skipped, and the lines "before N" and "after N" set Before and After.
+// A pattern in double quotes is a Go string literal, for bytes that
+// aren't UTF-8 or space at the ends:
+//
+//	before 256
+//	after 1024
+//	copyright
+//	(c)
+//	"\xa9"
+func ReadPrefilter(r io.Reader) (*Prefilter, error) {
+	var patterns []string
+	before, after := DefaultPrefilterBefore, DefaultPrefilterAfter
+
+	line := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		if fields := strings.Fields(pattern); len(fields) == 2 && (fields[0] == "before" || fields[0] == "after") {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("line %d: %s %q is not a number of bytes", line, fields[0], fields[1])
+			}
+			if fields[0] == "before" {
+				before = n
+			} else {
+				after = n
+			}
+			continue
+		}
+		if strings.HasPrefix(pattern, "\"") {
+			unquoted, err := strconv.Unquote(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s is not a quoted string", line, pattern)
+			}
+			pattern = unquoted
+		}
+		patterns = append(patterns, pattern)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	prefilter, err := NewPrefilter(patterns)
+	if err != nil {
+		return nil, err
+	}
+	prefilter.Before, prefilter.After = before, after
+	return prefilter, nil
+}
+
+// Returns the prefilter of the patterns in the file at path
+func ReadPrefilterFile(path string) (*Prefilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefilter, err := ReadPrefilter(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return prefilter, nil
+}
+
+// Identifies the patterns and settings of the prefilter, for caches of
+// what was found with it
+func (prefilter *Prefilter) Signature() string {
+	if prefilter == nil {
+		return ""
+	}
+
+	hash := sha1.New()
+	fmt.Fprintf(hash, "before %d\nafter %d\n", prefilter.Before, prefilter.After)
+	for _, pattern := range prefilter.Patterns {
+		fmt.Fprintf(hash, "%q\n", pattern)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ASCII letters in lower case, every other byte as it is
+func foldByte(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// Builds the automaton: a trie of the patterns whose missing transitions
+// are filled in, breadth first, from the state of the longest proper
+// suffix that is also in the trie
+func (prefilter *Prefilter) compile() {
+	prefilter.next = [][256]int32{{}}
+	prefilter.out = []int{0}
+
+	for _, pattern := range prefilter.Patterns {
+		state := int32(0)
+		for i := 0; i < len(pattern); i++ {
+			b := foldByte(pattern[i])
+			if prefilter.next[state][b] == 0 {
+				prefilter.next = append(prefilter.next, [256]int32{})
+				prefilter.out = append(prefilter.out, 0)
+				prefilter.next[state][b] = int32(len(prefilter.next) - 1)
+			}
+			state = prefilter.next[state][b]
+		}
+		if len(pattern) > prefilter.out[state] {
+			prefilter.out[state] = len(pattern)
+		}
+	}
+
+	fail := make([]int32, len(prefilter.next))
+	var queue []int32
+	for b := 0; b < 256; b++ {
+		if child := prefilter.next[0][b]; child != 0 {
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if prefilter.out[fail[state]] > prefilter.out[state] {
+			prefilter.out[state] = prefilter.out[fail[state]]
+		}
+		for b := 0; b < 256; b++ {
+			child := prefilter.next[state][b]
+			if child == 0 {
+				prefilter.next[state][b] = prefilter.next[fail[state]][b]
+				continue
+			}
+			fail[child] = prefilter.next[fail[state]][b]
+			queue = append(queue, child)
+		}
+	}
+
+	// upper case letters go where their lower case ones do
+	for state := range prefilter.next {
+		for b := 'A'; b <= 'Z'; b++ {
+			prefilter.next[state][b] = prefilter.next[state][b+'a'-'A']
+		}
+	}
+}
+
+// Returns the start and end of every hit in text, in order.  Of patterns
+// that end at the same byte, the longest is the hit.
+func (prefilter *Prefilter) FindAllIndex(text []byte) [][]int {
+	var hits [][]int
+	state := int32(0)
+	for i, b := range text {
+		state = prefilter.next[state][b]
+		if n := prefilter.out[state]; n != 0 {
+			hits = append(hits, []int{i + 1 - n, i + 1})
+		}
+	}
+	return hits
+}
+
+// Returns the parts of text to tag: Before bytes before each hit to After
+// bytes after it, out to the start and end of their lines if those are
+// within reach and otherwise in to the nearest space, with the parts
+// that overlap merged.  A part takes in the line before its hit too, for
+// a notice that runs onto the line of the hit, as "All rights reserved"
+// does after the holder.  A nil prefilter tags all of text.
+func (prefilter *Prefilter) windows(text []byte) [][]int {
+	if prefilter == nil {
+		return [][]int{{0, len(text)}}
+	}
+
+	var windows [][]int
+	for _, hit := range prefilter.FindAllIndex(text) {
+		start := windowStart(text, hit[0], prefilter.Before)
+		end := windowEnd(text, hit[1], prefilter.After)
+		if last := len(windows) - 1; last >= 0 && start <= windows[last][1] {
+			if end > windows[last][1] {
+				windows[last][1] = end
+			}
+			continue
+		}
+		windows = append(windows, []int{start, end})
+	}
+	return windows
+}
+
+func windowStart(text []byte, hitStart int, before int) int {
+	min := hitStart - before
+	if min < 0 {
+		min = 0
+	}
+	// the start of the line before that of the hit
+	end := hitStart
+	if i := bytes.LastIndexByte(text[min:end], '\n'); i >= 0 {
+		end = min + i
+	}
+	if i := bytes.LastIndexByte(text[min:end], '\n'); i >= 0 {
+		return min + i + 1
+	}
+	if min == 0 {
+		return 0
+	}
+	if i := bytes.IndexAny(text[min:end], " \t"); i >= 0 {
+		return min + i + 1
+	}
+	if end < hitStart {
+		return end + 1
+	}
+	return min
+}
+
+func windowEnd(text []byte, hitEnd int, after int) int {
+	max := hitEnd + after
+	if max >= len(text) {
+		return len(text)
+	}
+	if i := bytes.LastIndexByte(text[hitEnd:max], '\n'); i >= 0 {
+		return hitEnd + i + 1
+	}
+	if i := bytes.LastIndexAny(text[hitEnd:max], " \t"); i >= 0 {
+		return hitEnd + i
+	}
+	return max
+}
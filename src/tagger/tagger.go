@@ -35,14 +35,13 @@ package tagger
 
 import (
 	"bytes"
-	"io/ioutil"
+	"math"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// global const:
-const numOfTags int = 26
-
 // global regex
 var copyright = regexp.MustCompile("(\\\\[(]co)")
 
@@ -55,17 +54,58 @@ type TagFrequency struct {
 
 // The Tagger Object
 type Tagger struct {
+	// the part of speech tagging model, one of Kinds()
+	Model POSTagger
+	// the company suffixes, organizations and abbreviations the
+	// tokenizer, the sentence splitter and the tagger respect; it is
+	// only read while tagging, so add to it before
+	Lexicon *Lexicon
+	// the languages other than English the notices are found in; their
+	// lexicons are added to Lexicon when the tagger is made
+	Languages *LanguageSet
+	// the patterns Match, Extract and FindAllIndex look for before
+	// tagging the text around them, nil to tag all of the text
+	Prefilter *Prefilter
+	// the confidence, from 0 to 1, a notice must have for Match,
+	// Extract, FindAllIndex and FindAll to find it; 0 finds them all
+	Threshold float64
+	// identifies the model and the data it was trained from
+	Signature string
+
+	// the grammar of copyright notices, see Grammar and SetGrammar, and
+	// the DFA compiled from it
+	grammar     *NoticeGrammar
+	noticeTable *noticeTable
+}
+
+// The bigram hidden Markov model the tagger has always used: the
+// probability of each tag given the word (the unigram dictionary) and
+// given the tag before it (the transition matrix)
+type Bigram struct {
 	Dictionary  map[string][]TagFrequency
 	TransMatrix [][]float32
-	// for the copyright extraction
-	CopyrightDFA  map[Tri]int
-	CopyrightSyms string
+	// for the words not in the dictionary, nil in models saved before it
+	Unknown *UnknownModel
+
+	tags  *TagSet
+	vocab *vocabulary
+	// the log of TransMatrix[prev][tag] at prev*len(tags)+tag
+	logTrans []float64
 }
 
 type TaggedWord struct {
 	word      string
 	tag       string
 	byteStart int
+	// for a word or phrase of another language that a notice is found
+	// by, the English keyword the notice grammar reads it as and the
+	// language, see language.go
+	keyword string
+	lang    string
+	// how probable the model found the tag, against the other tags the
+	// word could have had; 0 for a model that doesn't say, or when not
+	// asked for, which is most of the time, see tagBytes()
+	tagProb float32
 }
 
 // three variable structure used in DFA translation
@@ -75,117 +115,86 @@ type Tri struct {
 	pos   string
 }
 
-// create maps for converting tag string to integer and vice versa
-var TagStrToInt = make(map[string]int)
-var TagIntToStr = make(map[int]string)
-
-// intitalizes the map used to convert the integer and string
-// representation of part of speech tags
-func initTagConversionMap() {
-
-	TagStrToInt["bos"] = 0
-	TagStrToInt["$"] = 1
-	TagStrToInt["\""] = 2
-	TagStrToInt["("] = 3
-	TagStrToInt[")"] = 4
-	TagStrToInt[","] = 5
-	TagStrToInt["--"] = 6
-	TagStrToInt["."] = 7
-	TagStrToInt[":"] = 8
-	TagStrToInt["cc"] = 9
-	TagStrToInt["cd"] = 10
-	TagStrToInt["dt"] = 11
-	TagStrToInt["fw"] = 12
-	TagStrToInt["jj"] = 13
-	TagStrToInt["ls"] = 14
-	TagStrToInt["nn"] = 15
-	TagStrToInt["np"] = 16
-	TagStrToInt["pos"] = 17
-	TagStrToInt["pr"] = 18
-	TagStrToInt["rb"] = 19
-	TagStrToInt["sym"] = 20
-	TagStrToInt["to"] = 21
-	TagStrToInt["uh"] = 22
-	TagStrToInt["vb"] = 23
-	TagStrToInt["md"] = 24
-	TagStrToInt["in"] = 25
-
-	TagIntToStr[0] = "bos"
-	TagIntToStr[1] = "$"
-	TagIntToStr[2] = "\""
-	TagIntToStr[3] = "("
-	TagIntToStr[4] = ")"
-	TagIntToStr[5] = ","
-	TagIntToStr[6] = "--"
-	TagIntToStr[7] = "."
-	TagIntToStr[8] = ":"
-	TagIntToStr[9] = "cc"
-	TagIntToStr[10] = "cd"
-	TagIntToStr[11] = "dt"
-	TagIntToStr[12] = "fw"
-	TagIntToStr[13] = "jj"
-	TagIntToStr[14] = "ls"
-	TagIntToStr[15] = "nn"
-	TagIntToStr[16] = "np"
-	TagIntToStr[17] = "pos"
-	TagIntToStr[18] = "pr"
-	TagIntToStr[19] = "rb"
-	TagIntToStr[20] = "sym"
-	TagIntToStr[21] = "to"
-	TagIntToStr[22] = "uh"
-	TagIntToStr[23] = "vb"
-	TagIntToStr[24] = "md"
-	TagIntToStr[25] = "in"
-}
-
 // Initialization for the Tagger object
 // Takes a file path and will create the unigram dictionary and transition
-// matrix required for sentence tagging and NLP processing
+// matrix required for sentence tagging and NLP processing.  Panics if the
+// corpus can't be read; NewFromCorpus returns the error instead.
 func New(path string) *Tagger {
+	copyrightTagger, err := NewFromCorpus(path, FormatNative, NewTrainOptions(DefaultKind))
+	if err != nil {
+		panic(err)
+	}
 
-	// initialize my TagStrToInt and TagIntToStr
-	initTagConversionMap()
+	return copyrightTagger
+}
+
+// Creates the unigram dictionary and transition matrix from the words
+// of a corpus and their tags
+func trainBigram(corpus []TaggedWord, options TrainOptions) POSTagger {
+	tags := options.Tags
 
 	// initialize the dictionary
 	var dictionary = make(map[string][]TagFrequency)
 
 	// Initialize the transition Matrix,
-	var transMatrix = make([][]float32, numOfTags)
+	var transMatrix = make([][]float32, tags.Len())
 	for row := range transMatrix {
-		transMatrix[row] = make([]float32, numOfTags)
+		transMatrix[row] = make([]float32, tags.Len())
 	}
 
-	// read through the corpus file to populate the dictionary and transMatrix
-	raw, err := ioutil.ReadFile(path)
-	if err != nil {
-		panic("could not read the file for tagging")
-	}
-	rawString := string(raw[:])
-
-	prevTag := "."
-	currTag := ""
-	// I need to use the split feature on the coprus. So the input Corpus must have three
-	// spaces between each word|~|tag pair. Once I have each word|~|tag pair I can
-	// then split on the delimeter. Assumptions are made but I am assuming a safe input
-	// file which I feel is acceptable, since if you save a file you know what it will
-	// look like.
-	textArry := strings.Split(rawString, "   ")
-	textArry = textArry[:len(textArry)-1]
-	for _, word := range textArry {
-		wrdArry := strings.Split(word, "|~|")
-		currTag = wrdArry[1]
-		incrementUnigramWrd(dictionary, wrdArry[0], currTag)
-		incrementTransMatrix(&transMatrix, TagStrToInt[prevTag], TagStrToInt[currTag])
+	prevTag := tags.period
+	for _, taggedWord := range corpus {
+		currTag, _ := tags.Index(taggedWord.tag)
+		incrementUnigramWrd(dictionary, taggedWord.word, taggedWord.tag)
+		incrementTransMatrix(&transMatrix, prevTag, currTag)
 		prevTag = currTag
 	}
 	// everything is counted now convert the dictionary and TransMatrix to probabilistic
 	convertDictToProb(dictionary)
 	convertTransMatrixToProb(&transMatrix)
 
+	return newBigram(tags, dictionary, transMatrix, learnUnknown(corpus, tags, options.Unknown))
+}
+
+// Wraps a trained part of speech model, from a corpus or a saved model,
+// into a Tagger ready for copyright extraction
+func newTagger(model POSTagger, signature string) *Tagger {
+
 	// SETUP THE COPYRIGHT DFA
-	symbols, dfa := mkNoticeDFA()
+	grammar := DefaultGrammar()
+	languages := DefaultLanguages()
+	lexicon := DefaultLexicon()
+	languages.addLexicons(lexicon)
+
+	return &Tagger{Model: model, Lexicon: lexicon, Languages: languages,
+		Prefilter: DefaultPrefilter(), Signature: signature,
+		grammar: grammar, noticeTable: compileNoticeDFA(grammar, model.Tags())}
+}
+
+// Wraps a trained dictionary, transition matrix and unknown word model,
+// from a corpus or a saved model, into a Bigram with the tag set they are
+// indexed by
+func newBigram(tags *TagSet, dictionary map[string][]TagFrequency, transMatrix [][]float32, unknown *UnknownModel) *Bigram {
 
-	return &Tagger{Dictionary: dictionary, TransMatrix: transMatrix, CopyrightDFA: dfa, CopyrightSyms: symbols}
+	// the Viterbi algorithm works with log probabilities
+	numOfTags := tags.Len()
+	logTrans := make([]float64, numOfTags*numOfTags)
+	for row := 0; row < numOfTags; row++ {
+		for col := 0; col < numOfTags; col++ {
+			logTrans[row*numOfTags+col] = math.Log(float64(transMatrix[row][col]))
+		}
+	}
+
+	return &Bigram{Dictionary: dictionary, TransMatrix: transMatrix, Unknown: unknown,
+		tags: tags, vocab: newVocabulary(tags, dictionary), logTrans: logTrans}
+}
+
+func (bigram *Bigram) Kind() string {
+	return "bigram"
+}
+
+func (bigram *Bigram) Tags() *TagSet {
+	return bigram.tags
 }
 
 // This is the counter of tag transitions. Moving from one part of speech tag
@@ -242,9 +251,9 @@ func convertDictToProb(dictionary map[string][]TagFrequency) {
 // I am using Laplace Smoothing across the transitional probability
 // This means that every transition has a small probability of happeing
 func convertTransMatrixToProb(transMatrix *[][]float32) {
-	// transMatrix is a global variable
 	var total float32
 
+	numOfTags := len(*transMatrix)
 	for row := 0; row < numOfTags; row++ {
 		total = float32(numOfTags)
 		for col := 0; col < numOfTags; col++ {
@@ -260,12 +269,16 @@ func convertTransMatrixToProb(transMatrix *[][]float32) {
 // Given a word with an unknown part of speech. Using a model based from the
 // Brill tagger, Krymolowski and Roth 1998 research (http://www.aclweb.org/anthology/P98-2186)
 // This returns a guessed part of speech for unknown words
+// Models saved before the unknown word model was learned still use this.
 func tagUnkown(word string) string {
 
-	// perform an N for loop checking for integer ascii value
-	var i int
-	for i = 0; i < len(word); i++ {
-		if word[i] > 47 && word[i] < 58 {
+	if tag, ok := symbolTag(word); ok {
+		return tag
+	}
+
+	// perform an N for loop checking for a digit
+	for _, r := range word {
+		if unicode.IsDigit(r) {
 			return "cd"
 		}
 	}
@@ -298,8 +311,8 @@ func tagUnkown(word string) string {
 	}
 
 	// perform an N for loop checking for capital letter
-	for i = 0; i < len(word); i++ {
-		if word[i] > 64 && word[i] < 91 {
+	for _, r := range word {
+		if unicode.IsUpper(r) {
 			return "np"
 		}
 	}
@@ -334,14 +347,58 @@ func tagUnkown(word string) string {
 	}
 }
 
+// Punctuation and symbols only Unicode has are tagged by their Unicode
+// category, there are too many of them for any corpus to have them all
+func symbolTag(word string) (string, bool) {
+	r, size := utf8.DecodeRuneInString(word)
+	if word == "" || r < utf8.RuneSelf || size != len(word) || !isSymbol(r) {
+		return "", false
+	}
+
+	switch {
+	case unicode.Is(unicode.Pd, r):
+		return "--", true
+	case unicode.In(r, unicode.Pi, unicode.Pf):
+		return "\"", true
+	case unicode.Is(unicode.Ps, r):
+		return "(", true
+	case unicode.Is(unicode.Pe, r):
+		return ")", true
+	default:
+		return "sym", true
+	}
+}
+
+// The other ways of writing the copyright sign: in HTML, in RTF and in
+// LaTeX.  formatSent rewrites them to the sign, padded with spaces so that
+// the byte offsets of the words after them don't change.
+var copyrightSigns = [][]byte{
+	[]byte("&copy;"), []byte("&#169;"), []byte("&#xa9;"), []byte("&#xA9;"),
+	[]byte("\\'a9"), []byte("\\'A9"),
+	[]byte("\\copyright{}"), []byte("\\copyright"),
+}
+
 // Performs several string substitutions so that the tagger has an easier job
 // These calls are to substitute parts of the string for other parts
 // Once the sentence is formatted correctly it returns the string
 func formatSent(rawBytes []byte) []byte {
+	// all of the substitutions start with one of these
+	if bytes.IndexByte(rawBytes, '\\') < 0 && bytes.IndexByte(rawBytes, '&') < 0 {
+		return rawBytes
+	}
+
 	// to ensure a propper formatting.
 	// replace weird copyright symbols
 	// replaces \(co with (c)
-	rawBytes = copyright.ReplaceAll(rawBytes, []byte("(c) ")) // added extra space to preserve byte offset
+	if bytes.Contains(rawBytes, []byte("\\(co")) {
+		rawBytes = copyright.ReplaceAll(rawBytes, []byte("(c) ")) // added extra space to preserve byte offset
+	}
+	for _, sign := range copyrightSigns {
+		if bytes.Contains(rawBytes, sign) {
+			padded := append([]byte("©"), bytes.Repeat([]byte(" "), len(sign)-len("©"))...)
+			rawBytes = bytes.Replace(rawBytes, sign, padded, -1)
+		}
+	}
 
 	// replace contractions
 	// for byte preservation can not do these, but for more accurate tagging
@@ -359,53 +416,166 @@ func formatSent(rawBytes []byte) []byte {
 	return rawBytes
 }
 
-// returns true if the given byte is a white space character
-func isSpace(b ...byte) bool {
+// returns true if the given rune separates words: any Unicode white space,
+// such as the non-breaking space, or the invisible zero width space and
+// byte order mark
+func isSpace(r rune) bool {
+	return unicode.IsSpace(r) || r == '\u200b' || r == '\ufeff'
+}
+
+// returns true if the given rune is a word of its own: the ASCII symbols
+// and any Unicode punctuation or symbol, such as ©, ®, ™ and the em-dash
+func isSymbol(r rune) bool {
+	return strings.ContainsRune("~!`@#$%^&*()[]_+-=|}{:;'\"/\\.?><,", r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// The copyright sign in Latin-1, which shows up in files that aren't UTF-8
+const latin1Copyright = 0xa9
+
+// Returns the rune at the start of rawBytes and its length in bytes.
+// Bytes that aren't UTF-8 (Latin-1 text, say) are returned as
+// utf8.RuneError and belong to the word around them, except for a
+// Latin-1 copyright sign which is returned as ©.
+func decodeRune(rawBytes []byte) (rune, int) {
+	r, size := utf8.DecodeRune(rawBytes)
+	if r == utf8.RuneError && size == 1 && rawBytes[0] == latin1Copyright {
+		return '©', 1
+	}
+	return r, size
+}
 
-	return bytes.Contains([]byte(" \n\r\t"), b)
+// returns true if the given bytes start with a letter or digit, so that
+// whatever comes before them doesn't end a word
+func continuesWord(rawBytes []byte) bool {
+	if len(rawBytes) == 0 {
+		return false
+	}
+	r, size := decodeRune(rawBytes)
+	return !isSpace(r) && !isWordSymbol(r, size)
 }
 
-// returns true if the given byte is a ASCII symbolic character
-func isSymbol(b ...byte) bool {
-	return bytes.Contains([]byte("~!`@#$%^&*()[]_+-=|}{:;'\"/\\.?><,"), b)
+// returns true if the rune decodeRune returned is a word of its own
+func isWordSymbol(r rune, size int) bool {
+	return isSymbol(r) && !(r == utf8.RuneError && size == 1)
+}
+
+// returns true for the apostrophe and the right single quote, which
+// typeset text uses as an apostrophe
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '\u2019'
+}
+
+// the contractions and the possessive that are split off the word they
+// follow, as in "it's", "we're" and "the author's"
+var clitics = []string{"s", "re", "ve", "ll", "d", "m"}
+
+// Given the bytes following an apostrophe that follows a word returns the
+// length of the clitic they start, or 0 if they don't start one
+func cliticLen(rawBytes []byte) int {
+	for _, clitic := range clitics {
+		if len(rawBytes) >= len(clitic) && strings.EqualFold(string(rawBytes[:len(clitic)]), clitic) &&
+			!continuesWord(rawBytes[len(clitic):]) {
+			return len(clitic)
+		}
+	}
+	return 0
 }
 
 // Given a slice of raw bytes will convert this into a slice of
 // TaggedWord objects with no tag set. This slice of TaggedWord objects will
-// then be given to the tagger for determining the part of speech tag
-func mkWrdArray(rawBytes []byte) []TaggedWord {
+// then be given to the tagger for determining the part of speech tag.
+// Words are split on runes, so multi-byte characters are never split;
+// each word's byteStart is its exact offset in rawBytes.
+//
+// Contractions and possessives are split the way the Penn Treebank does
+// it: "doesn't" is "does" "n't", "it's" is "it" "'s" and "author's" is
+// "author" "'s".  Any other apostrophe is a symbol of its own.
+//
+// The entries of the lexicon with a period or other symbol in them, such
+// as "Inc." and "S.A.", are kept as one word.
+func mkWrdArray(rawBytes []byte, lexicon *Lexicon) []TaggedWord {
 
 	currByte := 0
 	wordStart := currByte
-	var taggedWords []TaggedWord = make([]TaggedWord, 0)
+	// most words are a few letters and a space
+	var taggedWords []TaggedWord = make([]TaggedWord, 0, len(rawBytes)/4+1)
+
+	addWord := func(start int, end int) {
+		if start != end { // add the word if I can
+			taggedWords = append(taggedWords, TaggedWord{word: string(rawBytes[start:end]), tag: "", byteStart: start})
+		}
+	}
 
 	for currByte < len(rawBytes) {
-		if isSpace(rawBytes[currByte]) {
-			if wordStart != currByte { // add the word if I can
-				taggedWords = append(taggedWords, TaggedWord{word: string(rawBytes[wordStart:currByte]), tag: "", byteStart: wordStart})
+		if wordStart == currByte {
+			if n := lexicon.compoundLen(rawBytes[currByte:]); n != 0 {
+				addWord(currByte, currByte+n)
+				currByte += n
+				wordStart = currByte
+				continue
 			}
-			currByte++
-			wordStart = currByte
-		} else if isSymbol(rawBytes[currByte]) {
-			if wordStart != currByte { // add the word if I can
-				taggedWords = append(taggedWords, TaggedWord{word: string(rawBytes[wordStart:currByte]), tag: "", byteStart: wordStart})
+		}
+
+		r, size := decodeRune(rawBytes[currByte:])
+
+		if isApostrophe(r) && wordStart != currByte {
+			after := currByte + size
+			// n't takes the n from the word before it
+			if (rawBytes[currByte-1] == 'n' || rawBytes[currByte-1] == 'N') &&
+				after < len(rawBytes) && (rawBytes[after] == 't' || rawBytes[after] == 'T') &&
+				!continuesWord(rawBytes[after+1:]) {
+				addWord(wordStart, currByte-1)
+				addWord(currByte-1, after+1)
+				currByte = after + 1
+				wordStart = currByte
+				continue
 			}
+			if n := cliticLen(rawBytes[after:]); n != 0 {
+				addWord(wordStart, currByte)
+				addWord(currByte, after+n)
+				currByte = after + n
+				wordStart = currByte
+				continue
+			}
+		}
+
+		if isSpace(r) {
+			addWord(wordStart, currByte)
+			currByte += size
 			wordStart = currByte
-			currByte++
-			taggedWords = append(taggedWords, TaggedWord{word: string(rawBytes[wordStart:currByte]), tag: "", byteStart: wordStart})
+		} else if isWordSymbol(r, size) {
+			addWord(wordStart, currByte)
+			addWord(currByte, currByte+size)
+			currByte += size
 			wordStart = currByte
 		} else {
-			currByte++
+			currByte += size
 		}
 	}
 	taggedWords = append(taggedWords, TaggedWord{word: string(rawBytes[wordStart:currByte]), tag: "", byteStart: wordStart})
 	return taggedWords
 }
 
+// Typeset text uses curly quotes and several kinds of dash where the
+// corpus has plain ASCII ones; this is the spelling looked up in the
+// dictionary
+var wordNormalizer = strings.NewReplacer(
+	"\u2018", "'", "\u2019", "'", "\u201c", "\"", "\u201d", "\"",
+	"\u2010", "-", "\u2011", "-", "\u2012", "-", "\u2013", "-", "\u2014", "-", "\u2015", "-",
+	"\xa9", "©",
+)
+
 // Given any string this will return a slice of TaggedWord objects
 // representing that word in the sentence and the part of speech for
 // that word
 func (copyrightTagger *Tagger) TagBytes(rawBytes []byte) []TaggedWord {
+	return copyrightTagger.tagBytes(rawBytes, false)
+}
+
+// TagBytes, with probs setting how probable the model found the tag of
+// each word.  Working that out is as slow as tagging, and only the
+// confidence of the notices found and Explain need it.
+func (copyrightTagger *Tagger) tagBytes(rawBytes []byte, probs bool) []TaggedWord {
 	// ERROR AND SANITIZATION CHECKS
 	var wrdArry []TaggedWord = make([]TaggedWord, 0)
 	if len(rawBytes) < 1 { // do I even need to do any work
@@ -415,73 +585,20 @@ func (copyrightTagger *Tagger) TagBytes(rawBytes []byte) []TaggedWord {
 	// perform several regular expression subs and other so that the string is in a desired
 	// form
 	rawBytes = formatSent(rawBytes)
-	// split the sentence propperly
-	wrdArry = mkWrdArray(rawBytes)
-
-	sentLength := len(wrdArry) + 1             // I need 1 more for the start of the sentence
-	sentMatrix := make([][]float32, numOfTags) // Create the sentence Matrix
-	for row := range sentMatrix {
-		sentMatrix[row] = make([]float32, sentLength)
-	}
-
-	// initialize the first column
-	var lastBestProb float32 = 1.0
-	var lastBestTag int = TagStrToInt["."]
-	var currBestProb float32 = 0.0
-	var currBestTag int = 0
-
-	sentMatrix[TagStrToInt["."]][0] = 1.0 // the max probability something can be
-	for wrdIndex := 0; wrdIndex < len(wrdArry); wrdIndex++ {
-		for tagIndex := 0; tagIndex < numOfTags; tagIndex++ {
-			var currTrans float32 = copyrightTagger.TransMatrix[lastBestTag][tagIndex]
-			var currProb float32 = lastBestProb * currTrans
-
-			if len(copyrightTagger.Dictionary[wrdArry[wrdIndex].word]) != 0 { // has the word been seen before?
-				if wrdArry[wrdIndex].word == "." || wrdArry[wrdIndex].word == "?" || wrdArry[wrdIndex].word == "!" {
-					sentMatrix[TagStrToInt["."]][wrdIndex+1] = 1.0
-				} else {
-					for _, tagObject := range copyrightTagger.Dictionary[wrdArry[wrdIndex].word] {
-						if TagIntToStr[tagIndex] == tagObject.tag {
-							sentMatrix[tagIndex][wrdIndex+1] = currProb * tagObject.freq
-						}
-					}
-				}
-				// check for the word not carring about capitalization
-			} else if len(copyrightTagger.Dictionary[strings.ToLower(wrdArry[wrdIndex].word)]) != 0 {
-				for _, tagObject := range copyrightTagger.Dictionary[strings.ToLower(wrdArry[wrdIndex].word)] {
-					if TagIntToStr[tagIndex] == tagObject.tag {
-						sentMatrix[tagIndex][wrdIndex+1] = currProb * tagObject.freq
-					}
-				}
-			} else { // Try to determine tag based on transitional probability and word itself
-				if currTrans >= 0.7 {
-					sentMatrix[tagIndex][wrdIndex+1] = currProb
-				} else {
-					likelyTag := tagUnkown(wrdArry[wrdIndex].word)
-					sentMatrix[TagStrToInt[likelyTag]][wrdIndex+1] = currProb * 0.95
-				}
-			}
-			// see if this is the best transition for next column
-			if currProb > currBestProb {
-				currBestProb = currProb
-				currBestTag = tagIndex
-			}
-		}
-		lastBestProb = currBestProb
-		lastBestTag = currBestTag
-	}
-	// Sentence Matrix Created.
-	// Now walk through the matrix assigning the best tag to each word
-	for wrdIndex := 0; wrdIndex < len(wrdArry); wrdIndex++ {
-		var tagProb float32 = 0.0
-		for tagIndex := 0; tagIndex < numOfTags; tagIndex++ {
-			if sentMatrix[tagIndex][wrdIndex+1] > tagProb {
-				tagProb = sentMatrix[tagIndex][wrdIndex+1]
-				wrdArry[wrdIndex].tag = TagIntToStr[tagIndex]
-			}
-		}
+	// split the sentence propperly, and the words of the scripts without
+	// spaces if there are any
+	unspaced := hasUnspaced(rawBytes)
+	wrdArry = mkWrdArray(rawBytes, copyrightTagger.Lexicon)
+	if unspaced {
+		wrdArry = copyrightTagger.Languages.splitWords(wrdArry)
 	}
 
+	// the lexicon knows better than the model what names are
+	copyrightTagger.Lexicon.tagWords(wrdArry)
+	copyrightTagger.Model.tagWords(wrdArry, probs)
+	// and the languages what their keywords are
+	wrdArry = copyrightTagger.Languages.markWords(wrdArry, rawBytes, unspaced)
+
 	// compress numbers and propper nouns that might have been split
 	wrdArry = compressNumInString(wrdArry)
 	wrdArry = compressNP(wrdArry)
@@ -489,6 +606,104 @@ func (copyrightTagger *Tagger) TagBytes(rawBytes []byte) []TaggedWord {
 	return wrdArry
 }
 
+// Whether the word at index i of the words starts a sentence: it is the
+// first or comes after a full stop
+func startsSentence(wrdArry []TaggedWord, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := wrdArry[i-1].word
+	return prev == "." || prev == "?" || prev == "!"
+}
+
+// The Viterbi algorithm: sets the tag of each word to the one on the most
+// likely path of tags through the sentence.  Every column of the sentence
+// matrix holds, for each tag the word can have, the log probability of the
+// best path ending in that tag along with a backpointer to the tag before
+// it on that path.  Log probabilities are used so that long sentences
+// don't underflow.
+func (bigram *Bigram) TagWords(wrdArry []TaggedWord) {
+	bigram.tagWords(wrdArry, false)
+}
+
+func (bigram *Bigram) tagWords(wrdArry []TaggedWord, probs bool) {
+	if len(wrdArry) == 0 {
+		return
+	}
+
+	tags := bigram.tags
+	numOfTags := tags.Len()
+	scratch := getScratch(numOfTags)
+	defer scratchPool.Put(scratch)
+
+	// the start of the sentence is the same as coming after a period,
+	// the max probability something can be
+	start := [1]tagLogProb{{tags.period, 0}}
+	prevColumn, prevScores := start[:], []float64{0}
+
+	for wrdIndex := range wrdArry {
+		column := scratch.addColumn(tags, bigram.vocab, bigram.Unknown, wrdArry, wrdIndex)
+		// a path that is no more likely through any tag before comes
+		// from a period, as the start does
+		periodIndex := int32(indexOfTag(prevColumn, tags.period))
+
+		for _, cand := range column {
+			bestProb := math.Inf(-1)
+			bestPrev := periodIndex
+			for prevIndex, prev := range prevColumn {
+				prob := prevScores[prevIndex] + bigram.logTrans[prev.tag*numOfTags+cand.tag]
+				if prob > bestProb {
+					bestProb = prob
+					bestPrev = int32(prevIndex)
+				}
+			}
+			scratch.scores = append(scratch.scores, bestProb+cand.logProb)
+			scratch.backs = append(scratch.backs, bestPrev)
+		}
+		scratch.cellStart = append(scratch.cellStart, len(scratch.scores))
+		prevColumn = column
+		prevScores, _ = scratch.cells(wrdIndex)
+	}
+
+	// Sentence Matrix Created.
+	// Now find the best tag for the last word and follow the backpointers
+	last := len(wrdArry) - 1
+	bestIndex := indexOfTag(prevColumn, tags.period)
+	bestProb := math.Inf(-1)
+	if bestIndex >= 0 {
+		bestProb = prevScores[bestIndex]
+	}
+	for tagIndex := range prevColumn {
+		if prevScores[tagIndex] > bestProb {
+			bestProb = prevScores[tagIndex]
+			bestIndex = tagIndex
+		}
+	}
+	for wrdIndex := last; wrdIndex > 0; wrdIndex-- {
+		// a tag the word can't have is the period, whose backpointer is
+		// the period too
+		if bestIndex < 0 {
+			wrdArry[wrdIndex].tag = tags.Name(tags.period)
+			bestIndex = indexOfTag(scratch.column(wrdIndex-1), tags.period)
+			continue
+		}
+		wrdArry[wrdIndex].tag = tags.Name(scratch.column(wrdIndex)[bestIndex].tag)
+		if probs {
+			wrdArry[wrdIndex].tagProb = scratch.tagProb(wrdIndex, bestIndex)
+		}
+		_, backs := scratch.cells(wrdIndex)
+		bestIndex = int(backs[bestIndex])
+	}
+	if bestIndex < 0 {
+		wrdArry[0].tag = tags.Name(tags.period)
+	} else {
+		wrdArry[0].tag = tags.Name(scratch.column(0)[bestIndex].tag)
+		if probs {
+			wrdArry[0].tagProb = scratch.tagProb(0, bestIndex)
+		}
+	}
+}
+
 // The DFA required for number compression
 // into one number not number period number
 // START is start state
@@ -527,13 +742,49 @@ func mkNumCompressDFA() map[Tri]int {
 	return dfa
 }
 
+// The kinds of word the number compression DFA tells apart
+const (
+	numDigits      = iota // any word tagged cd
+	numPeriod             // "." tagged .
+	numQuestion           // "?" tagged .
+	numExclamation        // "!" tagged .
+	numPunct              // any other word tagged .
+	numOther              // anything else
+	numOfNumInputs
+)
+
+// The key of mkNumCompressDFA for each kind of word
+var numInputs = [numOfNumInputs]Tri{
+	numDigits:      {word: "X", pos: "cd"},
+	numPeriod:      {word: ".", pos: "."},
+	numQuestion:    {word: "?", pos: "."},
+	numExclamation: {word: "!", pos: "."},
+	numPunct:       {word: "", pos: "."},
+	numOther:       {word: "X", pos: "X"},
+}
+
+// mkNumCompressDFA as a table of the next state by state and kind of word
+var numCompressTable = compileNumCompressDFA()
+
+func compileNumCompressDFA() [INTERM + 1][numOfNumInputs]int {
+	dfa := mkNumCompressDFA()
+
+	var table [INTERM + 1][numOfNumInputs]int
+	for state := range table {
+		for input, key := range numInputs {
+			key.state = state
+			table[state][input] = dfa[key]
+		}
+	}
+	return table
+}
+
 // Given a slice of TaggedWord objects this will
 // compress floating point and numbers containing periods
-// that might have been split up by the tagger's formatting
+// that might have been split up by the tagger's formatting.  The words are
+// compressed in place, there are never more out than have been read.
 func compressNumInString(inSent []TaggedWord) []TaggedWord {
-	dfa := mkNumCompressDFA()
-
-	var finalSent []TaggedWord = make([]TaggedWord, 0)
+	finalSent := inSent[:0]
 
 	currentState := REJECT // the dead state
 	var compNum []string = make([]string, 0)
@@ -542,33 +793,44 @@ func compressNumInString(inSent []TaggedWord) []TaggedWord {
 
 	for _, taggedWord := range inSent {
 		// Make the transition to the next state based on the input
+		input := numOther
 		if taggedWord.tag == "." {
-			currentState = dfa[Tri{state: currentState, word: taggedWord.word, pos: taggedWord.tag}]
+			switch taggedWord.word {
+			case ".":
+				input = numPeriod
+			case "?":
+				input = numQuestion
+			case "!":
+				input = numExclamation
+			default:
+				input = numPunct
+			}
 		} else if taggedWord.tag == "cd" {
-			currentState = dfa[Tri{state: currentState, word: "X", pos: taggedWord.tag}]
-		} else {
-			currentState = dfa[Tri{state: currentState, word: "X", pos: "X"}]
+			input = numDigits
 		}
+		currentState = numCompressTable[currentState][input]
 
 		// Based on the current input decide how to save information
 		if currentState == START {
 			finalSent = append(finalSent, saveNum...)
-			compNum = nil
-			saveNum = nil
-			compNum = append(compNum, taggedWord.word)
+			compNum = append(compNum[:0], taggedWord.word)
 			saveStartByte = taggedWord.byteStart
-			saveNum = append(saveNum, taggedWord)
+			saveNum = append(saveNum[:0], taggedWord)
 		} else if currentState == INTERM {
 			compNum = append(compNum, ".")
 			saveNum = append(saveNum, taggedWord)
 		} else if currentState == REJECT {
 			finalSent = append(finalSent, saveNum...)
-			saveNum = nil
+			saveNum = saveNum[:0]
 			finalSent = append(finalSent, taggedWord)
 		} else if currentState == ACCEPT {
 			compNum = append(compNum, taggedWord.word)
-			saveNum = nil
-			saveNum = append(saveNum, TaggedWord{word: strings.Join(compNum, ""), tag: "cd", byteStart: saveStartByte})
+			// the number is as probable as the least probable of its parts
+			tagProb := taggedWord.tagProb
+			for _, part := range saveNum {
+				tagProb = minProb(tagProb, part.tagProb)
+			}
+			saveNum = append(saveNum[:0], TaggedWord{word: strings.Join(compNum, ""), tag: "cd", byteStart: saveStartByte, tagProb: tagProb})
 			currentState = START
 		}
 	}
@@ -582,25 +844,29 @@ func compressNumInString(inSent []TaggedWord) []TaggedWord {
 
 // Similar to the compressNumInString this recompresses
 // propper nouns that the tagger possibly separated to generalize
-// tagging and account for words it has not seen before.
+// tagging and account for words it has not seen before.  Only initials,
+// such as the "J." of "J. Smith", get their period back; abbreviations
+// such as "Inc." are kept whole by the tokenizer, see Lexicon.  In place
+// like compressNumInString.
 func compressNP(inSent []TaggedWord) []TaggedWord {
-	var finalSent []TaggedWord = make([]TaggedWord, 0)
+	finalSent := inSent[:0]
 
 	prevTag := ""
 	var saveWord []string = make([]string, 0)
 	var saveByteStart int
+	var saveProb float32
 	for _, taggedWord := range inSent {
 
-		if prevTag == "np" && taggedWord.word == "." {
+		if prevTag == "np" && taggedWord.word == "." && isInitial(saveWord[len(saveWord)-1]) {
 			saveWord = append(saveWord, ".")
-			finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart})
+			finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart, tagProb: saveProb})
 			saveWord = nil
 		} else if prevTag == "np" && taggedWord.tag == "np" {
-			finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart})
+			finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart, tagProb: saveProb})
 			saveWord = nil
 			saveWord = append(saveWord, taggedWord.word)
-		} else if prevTag == "np" && taggedWord.word != "." {
-			finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart}, taggedWord)
+		} else if prevTag == "np" {
+			finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart, tagProb: saveProb}, taggedWord)
 			saveWord = nil
 		} else if taggedWord.tag == "np" {
 			saveWord = append(saveWord, taggedWord.word)
@@ -609,16 +875,32 @@ func compressNP(inSent []TaggedWord) []TaggedWord {
 		}
 
 		saveByteStart = taggedWord.byteStart
+		saveProb = taggedWord.tagProb
 		prevTag = taggedWord.tag
 
 	}
 	if prevTag == "np" {
-		finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart})
+		finalSent = append(finalSent, TaggedWord{word: strings.Join(saveWord, ""), tag: "np", byteStart: saveByteStart, tagProb: saveProb})
 	}
 
 	return finalSent
 }
 
+// returns true if the word is a single capital letter
+func isInitial(word string) bool {
+	r, size := utf8.DecodeRuneInString(word)
+	return size == len(word) && unicode.IsUpper(r)
+}
+
+// The lesser of two tag probabilities, the other if one is 0 for not
+// known
+func minProb(a float32, b float32) float32 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
 func toString(inSent []TaggedWord) string {
 	var finalSent = make([]string, 0)
 	for _, taggedWord := range inSent {
@@ -0,0 +1,148 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file is the tag set of a model: the part of speech tags it tags
+// with and their indexes in its tables.  Every model has its own, so
+// models trained with different tag sets can be used side by side, and a
+// tag set never changes once it is made, so taggers built and used in
+// several goroutines at once share nothing they write to.
+
+package tagger
+
+import (
+	"fmt"
+)
+
+type TagSet struct {
+	names []string
+	index map[string]int
+	// the indexes of the tags every tag set has
+	bos    int
+	period int
+}
+
+// The tags every tag set has: bos, which is never given to a word but
+// stands for what comes before the corpus, and ".", which ends a sentence
+// and is where the hidden Markov models start
+var requiredTags = []string{"bos", "."}
+
+// The tag set of the default corpus and of models saved before a model
+// could have its own
+var defaultTagNames = []string{
+	"bos", "$", "\"", "(", ")", ",", "--", ".", ":", "cc", "cd", "dt", "fw",
+	"jj", "ls", "nn", "np", "pos", "pr", "rb", "sym", "to", "uh", "vb", "md", "in",
+}
+
+var defaultTags = mustTagSet(defaultTagNames)
+
+// The most tags a tag set can have; the trigram model's tables grow with
+// the cube of it
+const maxTags = 256
+
+// Returns a tag set of the tags in names, whose indexes are their places
+// in names.  It must have every one of the required tags.
+func NewTagSet(names []string) (*TagSet, error) {
+	if len(names) > maxTags {
+		return nil, fmt.Errorf("%d tags, at most %d can be used", len(names), maxTags)
+	}
+
+	tags := &TagSet{names: append([]string(nil), names...), index: make(map[string]int, len(names))}
+	for i, name := range tags.names {
+		if name == "" {
+			return nil, fmt.Errorf("tag %d is empty", i)
+		}
+		if _, ok := tags.index[name]; ok {
+			return nil, fmt.Errorf("tag %q is in the tag set twice", name)
+		}
+		tags.index[name] = i
+	}
+
+	for _, name := range requiredTags {
+		if _, ok := tags.index[name]; !ok {
+			return nil, fmt.Errorf("the tag set has no %q tag", name)
+		}
+	}
+	tags.bos = tags.index["bos"]
+	tags.period = tags.index["."]
+
+	return tags, nil
+}
+
+func mustTagSet(names []string) *TagSet {
+	tags, err := NewTagSet(names)
+	if err != nil {
+		panic(err)
+	}
+	return tags
+}
+
+// Returns the tag set of the default corpus, which models are trained
+// with unless TrainOptions say otherwise
+func DefaultTagSet() *TagSet {
+	return defaultTags
+}
+
+// The number of tags
+func (tags *TagSet) Len() int {
+	return len(tags.names)
+}
+
+// Returns the names of the tags in index order
+func (tags *TagSet) Names() []string {
+	return append([]string(nil), tags.names...)
+}
+
+// Returns the index of the tag, and whether it is in the tag set
+func (tags *TagSet) Index(name string) (int, bool) {
+	index, ok := tags.index[name]
+	return index, ok
+}
+
+// Returns the name of the tag at index
+func (tags *TagSet) Name(index int) string {
+	return tags.names[index]
+}
+
+// Returns true if the tag sets have the same tags in the same order
+func (tags *TagSet) Equal(other *TagSet) bool {
+	if len(tags.names) != len(other.names) {
+		return false
+	}
+	for i := range tags.names {
+		if tags.names[i] != other.names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The tags a corpus can give a word: any but bos
+func (tags *TagSet) corpusTag(name string) (string, bool) {
+	index, ok := tags.index[name]
+	return name, ok && index != tags.bos
+}
@@ -0,0 +1,261 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file is the trigram hidden Markov model.  Like the bigram model it
+// uses the probability of each tag given the word, but the probability of
+// a tag depends on the two tags before it rather than one.  Trigrams are
+// sparse in a small corpus, so the transition probabilities interpolate the
+// trigram, bigram and unigram estimates, weighted by deleted interpolation
+// (Brants, TnT -- A Statistical Part-of-Speech Tagger, 2000).
+
+package tagger
+
+import (
+	"math"
+)
+
+type Trigram struct {
+	Dictionary map[string][]TagFrequency
+	// TransMatrix[t1][t2][t3] is the probability of t3 following t1 t2
+	TransMatrix [][][]float32
+	// the weights of the unigram, bigram and trigram estimates
+	Lambda [3]float32
+	// for the words not in the dictionary, nil in models saved before it
+	Unknown *UnknownModel
+
+	tags  *TagSet
+	vocab *vocabulary
+	// the log of TransMatrix[t1][t2][t3] at (t1*len(tags)+t2)*len(tags)+t3
+	logTrans []float64
+}
+
+// Counts the tags of the corpus in threes and interpolates the estimates
+func trainTrigram(corpus []TaggedWord, options TrainOptions) POSTagger {
+	tags := options.Tags
+	numOfTags := tags.Len()
+	var dictionary = make(map[string][]TagFrequency)
+
+	var uni = make([]float64, numOfTags)
+	var bi = make([][]float64, numOfTags)
+	var tri = make([][][]float64, numOfTags)
+	for t1 := range tri {
+		bi[t1] = make([]float64, numOfTags)
+		tri[t1] = make([][]float64, numOfTags)
+		for t2 := range tri[t1] {
+			tri[t1][t2] = make([]float64, numOfTags)
+		}
+	}
+
+	// like the bigram model the start of the corpus is the same as coming
+	// after a period
+	t1, t2 := tags.period, tags.period
+	for _, taggedWord := range corpus {
+		incrementUnigramWrd(dictionary, taggedWord.word, taggedWord.tag)
+		t3, _ := tags.Index(taggedWord.tag)
+		uni[t3]++
+		bi[t2][t3]++
+		tri[t1][t2][t3]++
+		t1, t2 = t2, t3
+	}
+	convertDictToProb(dictionary)
+	n := float64(len(corpus))
+
+	// Deleted interpolation: each trigram adds its count to the weight of
+	// whichever estimate predicts it best with the trigram itself left out
+	var lambda [3]float64
+	for t1 := range tri {
+		for t2 := range tri[t1] {
+			for t3, count := range tri[t1][t2] {
+				if count == 0 {
+					continue
+				}
+				estimates := [3]float64{
+					leaveOneOut(uni[t3], n),
+					leaveOneOut(bi[t2][t3], uni[t2]),
+					leaveOneOut(count, bi[t1][t2]),
+				}
+				best := 0
+				for i := range estimates {
+					if estimates[i] > estimates[best] {
+						best = i
+					}
+				}
+				lambda[best] += count
+			}
+		}
+	}
+	total := lambda[0] + lambda[1] + lambda[2]
+	if total == 0 {
+		lambda = [3]float64{1, 0, 0}
+		total = 1
+	}
+
+	var lambda32 [3]float32
+	for i := range lambda {
+		lambda32[i] = float32(lambda[i] / total)
+	}
+
+	// The unigram estimate is Laplace smoothed so that every transition
+	// has a small probability of happening
+	var transMatrix = make([][][]float32, numOfTags)
+	for t1 := range transMatrix {
+		transMatrix[t1] = make([][]float32, numOfTags)
+		for t2 := range transMatrix[t1] {
+			transMatrix[t1][t2] = make([]float32, numOfTags)
+			for t3 := range transMatrix[t1][t2] {
+				prob := lambda[0] * (uni[t3] + 1) / (n + float64(numOfTags))
+				if uni[t2] != 0 {
+					prob += lambda[1] * bi[t2][t3] / uni[t2]
+				}
+				if bi[t1][t2] != 0 {
+					prob += lambda[2] * tri[t1][t2][t3] / bi[t1][t2]
+				}
+				transMatrix[t1][t2][t3] = float32(prob / total)
+			}
+		}
+	}
+
+	return newTrigram(tags, dictionary, transMatrix, lambda32, learnUnknown(corpus, tags, options.Unknown))
+}
+
+// the estimate count/context with one occurrence taken out of both
+func leaveOneOut(count float64, context float64) float64 {
+	if context <= 1 {
+		return 0
+	}
+	return (count - 1) / (context - 1)
+}
+
+func newTrigram(tags *TagSet, dictionary map[string][]TagFrequency, transMatrix [][][]float32, lambda [3]float32, unknown *UnknownModel) *Trigram {
+	numOfTags := tags.Len()
+	logTrans := make([]float64, numOfTags*numOfTags*numOfTags)
+	for t1 := 0; t1 < numOfTags; t1++ {
+		for t2 := 0; t2 < numOfTags; t2++ {
+			for t3 := 0; t3 < numOfTags; t3++ {
+				logTrans[(t1*numOfTags+t2)*numOfTags+t3] = math.Log(float64(transMatrix[t1][t2][t3]))
+			}
+		}
+	}
+
+	return &Trigram{Dictionary: dictionary, TransMatrix: transMatrix, Lambda: lambda, Unknown: unknown,
+		tags: tags, vocab: newVocabulary(tags, dictionary), logTrans: logTrans}
+}
+
+func (trigram *Trigram) Kind() string {
+	return "trigram"
+}
+
+func (trigram *Trigram) Tags() *TagSet {
+	return trigram.tags
+}
+
+// The Viterbi algorithm over pairs of tags: the best path ending in the
+// tags u v at a word is found from the best paths ending in w u at the
+// word before it.  Only the tags a word can have are tried, which keeps
+// the number of pairs small.  The cells of a word are the pairs of a tag
+// of the word before it and a tag of the word, and the backpointer of a
+// cell is the tag of the word two before.
+func (trigram *Trigram) TagWords(wrdArry []TaggedWord) {
+	trigram.tagWords(wrdArry, false)
+}
+
+func (trigram *Trigram) tagWords(wrdArry []TaggedWord, probs bool) {
+	if len(wrdArry) == 0 {
+		return
+	}
+
+	n := trigram.tags.Len()
+	period := trigram.tags.period
+	scratch := getScratch(n)
+	defer scratchPool.Put(scratch)
+
+	// like the bigram model the start of the sentence is the same as
+	// coming after two periods
+	start := [1]tagLogProb{{period, 0}}
+	prevPrevTags, prevTags, pathProb := start[:], start[:], []float64{0}
+
+	for wrdIndex := range wrdArry {
+		tags := scratch.addColumn(trigram.tags, trigram.vocab, trigram.Unknown, wrdArry, wrdIndex)
+		periodIndex := int32(indexOfTag(prevPrevTags, period))
+
+		for u := range prevTags {
+			for _, v := range tags {
+				bestProb := math.Inf(-1)
+				bestPrev := periodIndex
+				for w := range prevPrevTags {
+					trans := (prevPrevTags[w].tag*n+prevTags[u].tag)*n + v.tag
+					prob := pathProb[w*len(prevTags)+u] + trigram.logTrans[trans]
+					if prob > bestProb {
+						bestProb = prob
+						bestPrev = int32(w)
+					}
+				}
+				scratch.scores = append(scratch.scores, bestProb+v.logProb)
+				scratch.backs = append(scratch.backs, bestPrev)
+			}
+		}
+		scratch.cellStart = append(scratch.cellStart, len(scratch.scores))
+
+		pathProb, _ = scratch.cells(wrdIndex)
+		prevPrevTags = prevTags
+		prevTags = tags
+	}
+
+	// find the best pair of tags for the last two words and follow the
+	// backpointers
+	bestU, bestV := 0, 0
+	for u := range prevPrevTags {
+		for v := range prevTags {
+			if pathProb[u*len(prevTags)+v] > pathProb[bestU*len(prevTags)+bestV] {
+				bestU, bestV = u, v
+			}
+		}
+	}
+
+	u, v := bestU, bestV
+	for wrdIndex := len(wrdArry) - 1; wrdIndex >= 0; wrdIndex-- {
+		// a path no more likely through any tag two words before is
+		// taken to come from a period, which the word may not have had
+		if v < 0 {
+			wrdArry[wrdIndex].tag = trigram.tags.Name(period)
+			u, v = -1, u
+			continue
+		}
+		column := scratch.column(wrdIndex)
+		wrdArry[wrdIndex].tag = trigram.tags.Name(column[v].tag)
+		if probs {
+			wrdArry[wrdIndex].tagProb = scratch.tagProb(wrdIndex, v)
+		}
+		if u < 0 {
+			u, v = -1, u
+			continue
+		}
+		_, backs := scratch.cells(wrdIndex)
+		u, v = int(backs[u*len(column)+v]), u
+	}
+}
@@ -0,0 +1,404 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+
+// This file is the model of words the hidden Markov models have never
+// seen.  Rather than a fixed list of English suffixes it learns, from the
+// rare words of the training corpus, how often each tag goes with each
+// suffix, each prefix and each word shape ("XxX" for "GmbH", "X" for
+// "LLC", "xXxXx" for "libFooBar").  Rare words are the most like the words
+// that will be unknown.  As in Brants, TnT -- A Statistical Part-of-Speech
+// Tagger, 2000, affixes are learned separately for words that start with
+// a capital letter and words that don't, so that "Pixar" isn't tagged like
+// "solar" (and here also for acronyms, for identifiers and for capitalized
+// words that start a sentence, which are as often as not ordinary words),
+// and the tags of a suffix are smoothed with those of the suffix
+// one shorter, down to the tags of all rare words.  Unlike TnT the shorter
+// estimate counts as a fixed number of extra words rather than a fixed
+// share, so that a suffix seen once or twice in a small corpus can't
+// overrule everything else.  The suffix, prefix and shape estimates are
+// then combined by their geometric mean: they are far from independent, so
+// multiplying them as naive Bayes does counts the same evidence over and
+// over.
+
+package tagger
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How the unknown word model is learned
+type UnknownOptions struct {
+	// the longest suffix and prefix, in runes, whose tags are learned;
+	// 0 leaves them out
+	MaxSuffix int
+	MaxPrefix int
+	// words seen more than this many times in the corpus aren't learned from
+	MaxFreq int
+	// whether the shape of the word is used
+	Shapes bool
+	// how many words the shorter affix, or for shapes the tags of all rare
+	// words, counts as in smoothing an estimate
+	Smoothing float64
+}
+
+// The options used unless others are given
+var DefaultUnknownOptions = UnknownOptions{MaxSuffix: 4, MaxPrefix: 2, MaxFreq: 10, Shapes: true, Smoothing: 1}
+
+type UnknownModel struct {
+	Options UnknownOptions
+	// how many times each tag was seen on the rare words of the corpus, in
+	// all, by suffix and prefix (keyed as affixKeys says) and by shape
+	Tags     []float32
+	Suffixes map[string][]float32
+	Prefixes map[string][]float32
+	Shapes   map[string][]float32
+
+	// the smoothed log probabilities of the tags, worked out from the counts
+	prior    []float64
+	suffixes map[string][]float64
+	prefixes map[string][]float64
+	shapes   map[string][]float64
+}
+
+// Counts the tags, of the tag set, of the rare words of a corpus
+func learnUnknown(corpus []TaggedWord, tags *TagSet, options UnknownOptions) *UnknownModel {
+	freq := make(map[string]int)
+	for _, taggedWord := range corpus {
+		freq[strings.ToLower(taggedWord.word)]++
+	}
+
+	unknown := &UnknownModel{
+		Options:  options,
+		Tags:     make([]float32, tags.Len()),
+		Suffixes: make(map[string][]float32),
+		Prefixes: make(map[string][]float32),
+		Shapes:   make(map[string][]float32),
+	}
+	count := func(counts map[string][]float32, key string, tag int) {
+		if counts[key] == nil {
+			counts[key] = make([]float32, tags.Len())
+		}
+		counts[key][tag]++
+	}
+
+	for i, taggedWord := range corpus {
+		if freq[strings.ToLower(taggedWord.word)] > options.MaxFreq {
+			continue
+		}
+		tag, _ := tags.Index(taggedWord.tag)
+		unknown.Tags[tag]++
+
+		wordCase, word := caseOf(corpus, i)
+		for _, key := range affixKeys(word, wordCase, options.MaxSuffix, suffix) {
+			count(unknown.Suffixes, key, tag)
+		}
+		for _, key := range affixKeys(word, wordCase, options.MaxPrefix, prefix) {
+			count(unknown.Prefixes, key, tag)
+		}
+		if options.Shapes {
+			count(unknown.Shapes, wordShape(word), tag)
+		}
+	}
+
+	unknown.estimate(tags)
+	return unknown
+}
+
+// the first n runes of word
+func prefix(word string, n int) string {
+	for i := range word {
+		if n == 0 {
+			return word[:i]
+		}
+		n--
+	}
+	return word
+}
+
+// The affixes of word from one rune long up to max runes, but always
+// shorter than the word: a whole word would only teach the tags of that
+// word, which the dictionary already has
+func affixes(word string, max int, affix func(string, int) string) []string {
+	var all []string
+	for n := 1; n <= max && n < utf8.RuneCountInString(word); n++ {
+		all = append(all, affix(word, n))
+	}
+	return all
+}
+
+// How the word at index i of the words is written: "x" if it has no
+// capital letters, "m" if it has some but doesn't start with one (an
+// identifier such as "libFooBar"), "A" if it is in all capitals, "S" if it
+// starts a sentence with a capital and "X" for any other capitalized word.
+// A word in all capitals next to another is text written in capitals
+// ("THIS SOFTWARE IS PROVIDED"), not an acronym, and is taken as the lower
+// case word it stands for.  Returns the case and the word, normalized.
+func caseOf(wrdArry []TaggedWord, i int) (string, string) {
+	word := normalizeWord(wrdArry[i].word)
+
+	r, _ := utf8.DecodeRuneInString(word)
+	switch {
+	case !unicode.IsUpper(r) && strings.ToLower(word) == word:
+		return "x", word
+	case !unicode.IsUpper(r):
+		return "m", word
+	case isAllCaps(word):
+		for _, j := range []int{i - 2, i - 1, i + 1, i + 2} {
+			if j >= 0 && j < len(wrdArry) && isAllCaps(wrdArry[j].word) {
+				return "x", strings.ToLower(word)
+			}
+		}
+		return "A", word
+	case startsSentence(wrdArry, i):
+		return "S", word
+	default:
+		return "X", word
+	}
+}
+
+// whether word has at least two letters and none of them lower case
+func isAllCaps(word string) bool {
+	letters := 0
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			return false
+		}
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	return letters > 1
+}
+
+// The keys the tags of the affixes of word are learned under, shortest
+// first: the case of the word (see caseOf), a space, then the affix in
+// lower case.  The first key, with no affix, holds the tags of all words
+// of that case.
+func affixKeys(word string, wordCase string, max int, affix func(string, int) string) []string {
+	keys := []string{wordCase + " "}
+	for _, a := range affixes(strings.ToLower(word), max, affix) {
+		keys = append(keys, wordCase+" "+a)
+	}
+	return keys
+}
+
+// Checks that counts read from a model can be estimated from: that there
+// is a count of every tag of the tag set for each key, and that each affix
+// is keyed as affixKeys says, with the affix one rune shorter there too
+func (unknown *UnknownModel) check(tags *TagSet) error {
+	if len(unknown.Tags) != tags.Len() {
+		return fmt.Errorf("unknown word model has %d tag counts for %d tags", len(unknown.Tags), tags.Len())
+	}
+	for _, counts := range []map[string][]float32{unknown.Suffixes, unknown.Prefixes, unknown.Shapes} {
+		for key, count := range counts {
+			if len(count) != tags.Len() {
+				return fmt.Errorf("unknown word model has %d tag counts for %q, of %d tags", len(count), key, tags.Len())
+			}
+		}
+	}
+	for _, affix := range []struct {
+		counts map[string][]float32
+		affix  func(string, int) string
+	}{{unknown.Suffixes, suffix}, {unknown.Prefixes, prefix}} {
+		for key := range affix.counts {
+			if len(key) < 2 || key[1] != ' ' {
+				return fmt.Errorf("unknown word model has a bad affix key %q", key)
+			}
+			wordCase, a := key[:2], key[2:]
+			shorter := ""
+			switch n := utf8.RuneCountInString(a); {
+			case n == 0:
+				continue
+			case n == 1:
+				shorter = wordCase
+			default:
+				shorter = wordCase + affix.affix(a, n-1)
+			}
+			if _, ok := affix.counts[shorter]; !ok {
+				return fmt.Errorf("unknown word model has %q but not %q", key, shorter)
+			}
+		}
+	}
+	return nil
+}
+
+// Works out the smoothed log probabilities from the counts, which are of
+// the tags of the tag set
+func (unknown *UnknownModel) estimate(tags *TagSet) {
+	// every tag but bos gets one extra count, so that no tag is ruled out
+	// for an unknown word
+	prior := make([]float64, tags.Len())
+	var total float64
+	for tag, n := range unknown.Tags {
+		if tag != tags.bos {
+			prior[tag] = float64(n) + 1
+			total += prior[tag]
+		}
+	}
+	for tag := range prior {
+		prior[tag] /= total
+	}
+
+	weight := unknown.Options.Smoothing
+	unknown.suffixes = smoothAffixes(unknown.Suffixes, prior, weight, suffix)
+	unknown.prefixes = smoothAffixes(unknown.Prefixes, prior, weight, prefix)
+	unknown.shapes = make(map[string][]float64)
+	for shape, counts := range unknown.Shapes {
+		unknown.shapes[shape] = smooth(counts, prior, weight)
+	}
+	unknown.prior = prior
+
+	for _, probs := range []map[string][]float64{unknown.suffixes, unknown.prefixes, unknown.shapes} {
+		for _, prob := range probs {
+			toLog(prob)
+		}
+	}
+	toLog(unknown.prior)
+}
+
+// Smooths the tags of each affix with those of the affix one rune shorter,
+// shortest first so that the shorter one is always ready
+func smoothAffixes(counts map[string][]float32, prior []float64, weight float64, affix func(string, int) string) map[string][]float64 {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return utf8.RuneCountInString(keys[i]) < utf8.RuneCountInString(keys[j])
+	})
+
+	probs := make(map[string][]float64)
+	for _, key := range keys {
+		wordCase, a := key[:2], key[2:]
+		shorter := prior
+		switch n := utf8.RuneCountInString(a); {
+		case n == 1:
+			shorter = probs[wordCase]
+		case n > 1:
+			shorter = probs[wordCase+affix(a, n-1)]
+		}
+		probs[key] = smooth(counts[key], shorter, weight)
+	}
+	return probs
+}
+
+// (count(tag) + weight P(tag|shorter)) / (count + weight)
+func smooth(counts []float32, shorter []float64, weight float64) []float64 {
+	var total float64
+	for _, n := range counts {
+		total += float64(n)
+	}
+	prob := make([]float64, len(counts))
+	for tag := range prob {
+		prob[tag] = (float64(counts[tag]) + weight*shorter[tag]) / (total + weight)
+	}
+	return prob
+}
+
+func toLog(prob []float64) {
+	for tag := range prob {
+		prob[tag] = math.Log(prob[tag])
+	}
+}
+
+// Fills in the log probability of each tag for the unknown word at index i
+// of the words, from the longest suffix and prefix of it that were learned
+// and its shape
+func (unknown *UnknownModel) emission(wrdArry []TaggedWord, i int, logProb []float64) {
+	wordCase, word := caseOf(wrdArry, i)
+	estimates := [][]float64{
+		longestAffix(unknown.suffixes, word, wordCase, unknown.Options.MaxSuffix, suffix),
+		longestAffix(unknown.prefixes, word, wordCase, unknown.Options.MaxPrefix, prefix),
+		unknown.shapes[wordShape(word)],
+	}
+
+	for tag := range logProb {
+		logProb[tag] = 0
+	}
+	n := 0
+	for _, prob := range estimates {
+		if prob == nil {
+			continue
+		}
+		for tag := range logProb {
+			logProb[tag] += prob[tag]
+		}
+		n++
+	}
+	if n == 0 {
+		copy(logProb, unknown.prior)
+		n = 1
+	}
+	// The hidden Markov models want the probability of the word given the
+	// tag, which by Bayes' rule is in proportion to that of the tag given
+	// the word over that of the tag
+	for tag := range logProb {
+		logProb[tag] /= float64(n)
+		if !math.IsInf(unknown.prior[tag], -1) {
+			logProb[tag] -= unknown.prior[tag]
+		}
+	}
+
+	// normalize, so that unknown words are on the same footing as known ones
+	best := math.Inf(-1)
+	for _, p := range logProb {
+		best = math.Max(best, p)
+	}
+	var total float64
+	for _, p := range logProb {
+		total += math.Exp(p - best)
+	}
+	norm := best + math.Log(total)
+	for tag := range logProb {
+		logProb[tag] -= norm
+	}
+}
+
+// Returns the estimate of the longest affix of the word there is one for,
+// or else that of the case alone.  The keys are those of affixKeys, made
+// in a buffer so that looking them up doesn't allocate.
+func longestAffix(probs map[string][]float64, word string, wordCase string, max int, affix func(string, int) string) []float64 {
+	loWord := strings.ToLower(word)
+	var buf [32]byte
+	key := append(append(buf[:0], wordCase...), ' ')
+	n := utf8.RuneCountInString(loWord) - 1
+	if n > max {
+		n = max
+	}
+	for ; n >= 1; n-- {
+		if prob, ok := probs[string(append(key, affix(loWord, n)...))]; ok {
+			return prob
+		}
+	}
+	return probs[string(key)]
+}
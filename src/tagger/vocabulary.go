@@ -0,0 +1,259 @@
+//
+// Copyright © 2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+// This file is what the hidden Markov models look words up in while
+// tagging.  The dictionary is keyed by word and lists tags by name, which
+// is how it is trained and saved; tagging wants, for each word, the
+// numbers of the tags it can have and their log probabilities, worked out
+// once when the model is made rather than for every word of every
+// sentence.  The Viterbi algorithm then only tries the tags a word can
+// have, and keeps its columns in buffers that are used over and over.
+
+package tagger
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// A tag a word can have and the log probability of the tag producing
+// the word
+type tagLogProb struct {
+	tag     int
+	logProb float64
+}
+
+// The words of a dictionary by number, in sorted order so that the same
+// dictionary always has the same numbers, and the tags each can have in
+// ascending order of tag
+type vocabulary struct {
+	ids       map[string]int32
+	emissions [][]tagLogProb
+}
+
+func newVocabulary(tags *TagSet, dictionary map[string][]TagFrequency) *vocabulary {
+	vocab := &vocabulary{ids: make(map[string]int32, len(dictionary))}
+	words := make([]string, 0, len(dictionary))
+	for word := range dictionary {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	dense := make([]float64, tags.Len())
+	for _, word := range words {
+		for tagIndex := range dense {
+			dense[tagIndex] = math.Inf(-1)
+		}
+		for _, tagObject := range dictionary[word] {
+			if tag, ok := tags.Index(tagObject.tag); ok {
+				dense[tag] = math.Log(float64(tagObject.freq))
+			}
+		}
+		emissions := appendFinite(nil, dense)
+		if len(emissions) == 0 {
+			continue
+		}
+		vocab.ids[word] = int32(len(vocab.emissions))
+		vocab.emissions = append(vocab.emissions, emissions)
+	}
+	return vocab
+}
+
+// Returns the tags of the word, or if it hasn't been seen those of the
+// word in lower case, and whether either has been seen
+func (vocab *vocabulary) lookup(word string) ([]tagLogProb, bool) {
+	id, ok := vocab.ids[word]
+	if !ok {
+		id, ok = vocab.ids[strings.ToLower(word)]
+	}
+	if !ok {
+		return nil, false
+	}
+	return vocab.emissions[id], true
+}
+
+// Appends the tags of the log probabilities that aren't math.Inf(-1), in
+// ascending order of tag
+func appendFinite(out []tagLogProb, logProb []float64) []tagLogProb {
+	for tag, p := range logProb {
+		if !math.IsInf(p, -1) {
+			out = append(out, tagLogProb{tag, p})
+		}
+	}
+	return out
+}
+
+// The emission probability given to the tag guessed for an unknown word,
+// the rest is spread over the other tags so a strong transition can
+// still win over the guess
+const unknownGuessProb = 0.95
+
+// Appends to out the tags that can have produced the word, with their log
+// probabilities, in ascending order of tag.  Words not in the vocabulary
+// are left to the unknown word model, or if there is none (models saved
+// before there was one) to tagUnkown.  The word is the one at index i of
+// the words; dense is room for a probability for each tag of the model.
+func emission(tags *TagSet, vocab *vocabulary, unknown *UnknownModel, wrdArry []TaggedWord, i int, dense []float64, out []tagLogProb) []tagLogProb {
+	word := wrdArry[i].word
+
+	// a word tagged already, by the lexicon, keeps its tag if the model
+	// has it
+	if wrdArry[i].tag != "" {
+		if tag, ok := tags.Index(wrdArry[i].tag); ok {
+			return append(out, tagLogProb{tag, 0})
+		}
+	}
+
+	if word == "." || word == "?" || word == "!" {
+		return append(out, tagLogProb{tags.period, 0})
+	}
+
+	// has the word been seen before? if not try without carring about capitalization
+	word = normalizeWord(word)
+	if emissions, ok := vocab.lookup(word); ok {
+		return append(out, emissions...)
+	}
+
+	// the empty word at the end of text is left to tagUnkown as well
+	if _, ok := symbolTag(word); !ok && word != "" && unknown != nil {
+		unknown.emission(wrdArry, i, dense)
+		return appendFinite(out, dense)
+	}
+
+	// Try to determine tag based on the word itself, leaving the rest
+	// to the transitional probability
+	otherProb := math.Log((1 - unknownGuessProb) / float64(tags.Len()-2))
+	for tagIndex := range dense {
+		dense[tagIndex] = otherProb
+	}
+	dense[tags.bos] = math.Inf(-1)
+	if tag, ok := tags.Index(tagUnkown(word)); ok {
+		dense[tag] = math.Log(unknownGuessProb)
+	}
+	return appendFinite(out, dense)
+}
+
+// Returns the spelling of the word that is looked up in the dictionary,
+// see wordNormalizer; most words are ASCII and have nothing to replace
+func normalizeWord(word string) string {
+	for i := 0; i < len(word); i++ {
+		if word[i] >= utf8.RuneSelf {
+			return wordNormalizer.Replace(word)
+		}
+	}
+	return word
+}
+
+// The columns of the Viterbi algorithm for a sentence: the tags each word
+// can have, one after the other, and the best paths that end in them
+type viterbiScratch struct {
+	dense []float64
+	// the tags of word k are cands[colStart[k]:colStart[k+1]]
+	cands    []tagLogProb
+	colStart []int
+	// the log probability of the best path ending in each cell of a word,
+	// and the cell of the word before it that path comes from
+	scores    []float64
+	backs     []int32
+	cellStart []int
+}
+
+var scratchPool = sync.Pool{New: func() interface{} { return new(viterbiScratch) }}
+
+// Returns scratch space for tagging with a model of numOfTags tags, to be
+// put back in scratchPool when done with
+func getScratch(numOfTags int) *viterbiScratch {
+	scratch := scratchPool.Get().(*viterbiScratch)
+	if cap(scratch.dense) < numOfTags {
+		scratch.dense = make([]float64, numOfTags)
+	}
+	scratch.dense = scratch.dense[:numOfTags]
+	scratch.cands = scratch.cands[:0]
+	scratch.colStart = append(scratch.colStart[:0], 0)
+	scratch.scores = scratch.scores[:0]
+	scratch.backs = scratch.backs[:0]
+	scratch.cellStart = append(scratch.cellStart[:0], 0)
+	return scratch
+}
+
+// Adds the column of tags the word at index i can have
+func (scratch *viterbiScratch) addColumn(tags *TagSet, vocab *vocabulary, unknown *UnknownModel, wrdArry []TaggedWord, i int) []tagLogProb {
+	start := len(scratch.cands)
+	scratch.cands = emission(tags, vocab, unknown, wrdArry, i, scratch.dense, scratch.cands)
+	scratch.colStart = append(scratch.colStart, len(scratch.cands))
+	return scratch.cands[start:]
+}
+
+// the tags of word k
+func (scratch *viterbiScratch) column(k int) []tagLogProb {
+	return scratch.cands[scratch.colStart[k]:scratch.colStart[k+1]]
+}
+
+// the scores and back pointers of the cells of word k
+func (scratch *viterbiScratch) cells(k int) ([]float64, []int32) {
+	start, end := scratch.cellStart[k], scratch.cellStart[k+1]
+	return scratch.scores[start:end], scratch.backs[start:end]
+}
+
+// Returns how probable the tag at index v of the column of word k is
+// against the other tags of the column, by the scores of the best paths
+// ending in each.  A trigram has a cell for each tag of the word before
+// too, which are summed over.
+func (scratch *viterbiScratch) tagProb(k int, v int) float32 {
+	scores, _ := scratch.cells(k)
+	width := len(scratch.column(k))
+	best := math.Inf(-1)
+	for _, score := range scores {
+		best = math.Max(best, score)
+	}
+	if math.IsInf(best, -1) {
+		return 0
+	}
+
+	var total, tag float64
+	for cell, score := range scores {
+		p := math.Exp(score - best)
+		total += p
+		if cell%width == v {
+			tag += p
+		}
+	}
+	return float32(tag / total)
+}
+
+// Returns the index of the tag in the column, or -1 if it isn't there
+func indexOfTag(column []tagLogProb, tag int) int {
+	for i, cand := range column {
+		if cand.tag == tag {
+			return i
+		}
+	}
+	return -1
+}
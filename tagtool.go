@@ -0,0 +1,457 @@
+//
+// Copyright © 2014-2015 Exablox Corporation,  All Rights Reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions
+// are met:
+//
+// 1. Redistributions of source code must retain the above copyright
+//    notice, this list of conditions and the following disclaimer.
+//
+// 2. Redistributions in binary form must reproduce the above copyright
+//    notice, this list of conditions and the following disclaimer in the
+//    documentation and/or other materials provided with the distribution.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+// FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE
+// COPYRIGHT HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT,
+// INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING,
+// BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES;
+// LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER
+// CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+// LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN
+// ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+// POSSIBILITY OF SUCH DAMAGE.
+//
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"tagger"
+	"time"
+	"version"
+)
+
+//
+// tagtool is for working on the tagger model used by license-extract: it
+// trains models from a corpus and measures how well they do, so that
+// changes to the corpus can be judged on evidence.
+//
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"train", "Train a tagger model from a corpus and save it", train},
+		{"evaluate", "Measure tagging accuracy and copyright detection", evaluate},
+		{"explain", "Show how copyright notices are found in a text, or why not", explain},
+	}
+}
+
+func usage() {
+	fmt.Printf("Usage: %s <command> [options]\n", os.Args[0])
+	fmt.Printf("\n")
+	fmt.Printf("Version %s. © 2014-2015 Exablox Corporation.  All Rights Reserved.\n", version.Version)
+	fmt.Printf("\n")
+	fmt.Printf("Commands:\n")
+	fmt.Printf("\n")
+	for _, c := range commands {
+		fmt.Printf("  %-10s %s\n", c.name, c.usage)
+	}
+	fmt.Printf("\n")
+	fmt.Printf("Use '%s <command> -h' for the options of a command.\n", os.Args[0])
+}
+
+func newFlagSet(name string, args string, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: %s %s %s\n", os.Args[0], name, args)
+		fmt.Printf("\n")
+		fmt.Printf("Options:\n")
+		fmt.Printf("\n")
+		fs.PrintDefaults()
+		fmt.Printf("\n")
+		fmt.Printf("Description:\n")
+		fmt.Printf("%s", description)
+	}
+	return fs
+}
+
+func readCorpus(path string, format tagger.CorpusFormat) ([]tagger.TaggedWord, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	corpus, err := tagger.ReadCorpus(raw, format)
+	if cerr, ok := err.(*tagger.CorpusError); ok {
+		cerr.Path = path
+	}
+
+	return corpus, err
+}
+
+// the kinds of model, one per line, for usage messages
+func kindList() string {
+	var lines []string
+	for _, kind := range tagger.Kinds() {
+		lines = append(lines, fmt.Sprintf("    %-12s %s", kind, tagger.AboutKind(kind)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Adds the flags that say how a model is trained
+func trainFlags(fs *flag.FlagSet) *tagger.TrainOptions {
+	options := tagger.NewTrainOptions(tagger.DefaultKind)
+	fs.StringVar(&options.Kind, "tagger", options.Kind, "Kind of model to train: "+strings.Join(tagger.Kinds(), ", "))
+	fs.IntVar(&options.Unknown.MaxSuffix, "suffix", options.Unknown.MaxSuffix, "Longest suffix, in letters, whose tags are learned for unknown words")
+	fs.IntVar(&options.Unknown.MaxPrefix, "prefix", options.Unknown.MaxPrefix, "Longest prefix, in letters, whose tags are learned for unknown words")
+	fs.IntVar(&options.Unknown.MaxFreq, "rare", options.Unknown.MaxFreq, "Learn unknown words from the words seen at most this many times")
+	fs.BoolVar(&options.Unknown.Shapes, "shapes", options.Unknown.Shapes, "Learn unknown words from the shapes of words too")
+	fs.Float64Var(&options.Unknown.Smoothing, "smoothing", options.Unknown.Smoothing, "How many words each shorter estimate counts as in smoothing the unknown word model")
+	return &options
+}
+
+func train(args []string) error {
+	var corpusPath string
+	var formatName string
+	var outPath string
+
+	fs := newFlagSet("train", "-corpus <corpus> -o <model>", `
+  Trains a tagger model from a tagged corpus and saves it, ready to be
+  given to license-extract with -model.  The accuracy of the model on
+  the corpus it was trained from is reported; see 'evaluate' for how to
+  measure it on text the model hasn't seen.  The kinds of model are:
+
+`+kindList()+`
+`)
+	fs.StringVar(&corpusPath, "corpus", "", "The tagged corpus to train the model from")
+	fs.StringVar(&formatName, "format", "auto", "Format of the corpus: native, brown, conll, ptb or auto")
+	fs.StringVar(&outPath, "o", "", "File to save the model to")
+	options := trainFlags(fs)
+	fs.Parse(args)
+
+	if corpusPath == "" || outPath == "" {
+		return fmt.Errorf("train: -corpus and -o are required")
+	}
+
+	format, err := tagger.ParseCorpusFormat(formatName)
+	if err != nil {
+		return err
+	}
+	corpus, err := readCorpus(corpusPath, format)
+	if err != nil {
+		return err
+	}
+	model, err := tagger.NewFromCorpus(corpusPath, format, *options)
+	if err != nil {
+		return err
+	}
+
+	outfile, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	err = model.SaveModel(outfile)
+	if err != nil {
+		outfile.Close()
+		return err
+	}
+	err = outfile.Close()
+	if err != nil {
+		return err
+	}
+
+	correct, total := model.Accuracy(corpus)
+	fmt.Printf("%s: %s model trained from %s, training accuracy %d/%d = %.3f\n",
+		outPath, options.Kind, corpusPath, correct, total, float64(correct)/float64(total))
+
+	return nil
+}
+
+func evaluate(args []string) error {
+	var corpusPath string
+	var formatName string
+	var modelPath string
+	var noticesPath string
+	var grammarPath string
+	var threshold float64
+	var folds int
+	var holdout float64
+
+	fs := newFlagSet("evaluate", "[options]", `
+  Measures how well a tagger model does.  The model evaluated is the one
+  given with -model, else one of the kind given with -tagger trained from
+  all of -corpus, else one of that kind made from the corpus built into
+  license-extract.  The kinds of model are:
+
+`+kindList()+`
+
+  Part of speech tagging is measured against the tagged corpus given with
+  -corpus.  With -folds or -holdout the corpus is split, and the part
+  being tagged is always tagged by a model trained on the rest of it.
+  For each tag the precision (of the words given the tag, how many the
+  corpus agrees on) and recall (of the words the corpus gives the tag,
+  how many were given it) are reported, followed by a confusion matrix.
+
+  Copyright detection as a whole is measured against a file of texts
+  labeled with whether or not they hold a copyright notice (-notices).
+  The format of the file is described in src/tagger/LabeledNotices.txt.
+  The notices are found by the grammar given with -grammar, in the format
+  of src/tagger/DefaultGrammar.txt, else by the built in one, and only
+  those with a confidence of at least -threshold count.
+`)
+	fs.StringVar(&corpusPath, "corpus", "", "The tagged corpus to measure tagging against")
+	fs.StringVar(&formatName, "format", "auto", "Format of the corpus: native, brown, conll, ptb or auto")
+	fs.StringVar(&modelPath, "model", "", "Load the model to evaluate from this file")
+	fs.StringVar(&noticesPath, "notices", "", "Measure copyright detection against this file of labeled texts")
+	fs.StringVar(&grammarPath, "grammar", "", "Find copyright notices by the grammar in this file")
+	fs.Float64Var(&threshold, "threshold", 0, "Only count copyright notices with at least this confidence, from 0 to 1")
+	fs.IntVar(&folds, "folds", 0, "Use k-fold cross validation on the corpus")
+	fs.Float64Var(&holdout, "holdout", 0, "Hold out this fraction of the corpus for evaluation")
+	options := trainFlags(fs)
+	fs.Parse(args)
+
+	if corpusPath == "" && noticesPath == "" {
+		return fmt.Errorf("evaluate: at least one of -corpus or -notices is required")
+	}
+	if (folds != 0 || holdout != 0) && corpusPath == "" {
+		return fmt.Errorf("evaluate: -folds and -holdout need -corpus")
+	}
+	if folds != 0 && holdout != 0 {
+		return fmt.Errorf("evaluate: -folds and -holdout are mutually exclusive")
+	}
+
+	format, err := tagger.ParseCorpusFormat(formatName)
+	if err != nil {
+		return err
+	}
+
+	var corpus []tagger.TaggedWord
+	if corpusPath != "" {
+		corpus, err = readCorpus(corpusPath, format)
+		if err != nil {
+			return err
+		}
+	}
+
+	var grammar *tagger.NoticeGrammar
+	if grammarPath != "" {
+		grammar, err = tagger.ReadGrammarFile(grammarPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	var model *tagger.Tagger
+	switch {
+	case modelPath != "":
+		model, err = tagger.NewFromModel(modelPath)
+	case corpusPath != "":
+		model, err = tagger.NewFromCorpus(corpusPath, format, *options)
+	default:
+		model, err = tagger.NewDefault(options.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	if grammar != nil {
+		model.SetGrammar(grammar)
+	}
+	model.Threshold = threshold
+
+	if corpusPath != "" {
+		e := tagger.NewEvaluation(model.Model.Tags())
+		start := time.Now()
+		switch {
+		case folds != 0:
+			evaluations, err := tagger.CrossValidate(corpus, folds, *options)
+			if err != nil {
+				return err
+			}
+			for fold, fe := range evaluations {
+				fmt.Printf("fold %d: accuracy %d/%d = %.3f\n", fold, fe.Correct(), fe.Total(), fe.Accuracy())
+				e.Add(fe)
+			}
+			fmt.Printf("\n")
+		case holdout != 0:
+			he, err := tagger.HoldOut(corpus, holdout, *options)
+			if err != nil {
+				return err
+			}
+			e.Add(he)
+		default:
+			e.Add(model.Evaluate(corpus))
+		}
+		elapsed := time.Since(start)
+
+		err = e.WriteReport(os.Stdout)
+		if err != nil {
+			return err
+		}
+		// training is included in the time with -folds and -holdout
+		fmt.Printf("\n%s: %d words in %s, %.0f words/s\n", model.Model.Kind(), e.Total(),
+			elapsed, float64(e.Total())/elapsed.Seconds())
+	}
+
+	if noticesPath != "" {
+		infile, err := os.Open(noticesPath)
+		if err != nil {
+			return err
+		}
+		notices, err := tagger.ReadLabeledNotices(infile)
+		infile.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", noticesPath, err)
+		}
+
+		if corpusPath != "" {
+			fmt.Printf("\n")
+		}
+		err = model.Detect(notices).WriteReport(os.Stdout, noticesPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// An explanation of one of the texts given to explain, for -json
+type explainedText struct {
+	Path string `json:"path,omitempty"`
+	*tagger.Explanation
+}
+
+func explain(args []string) error {
+	var text string
+	var modelPath string
+	var grammarPath string
+	var threshold float64
+	var asJSON bool
+	var colorMode string
+
+	fs := newFlagSet("explain", "[options] [file...]", `
+  Shows how copyright notices are found in the files given, or in the text
+  given with -text, to find out why a notice is missed or falsely found:
+  the windows of the text around the hits of the prefilter, the sentences
+  they are split into, each word with its part of speech tag and how
+  probable the model found it, the state of the notice grammar before and
+  after each word, and every notice the grammar ended, whether it was kept
+  or passed over and why.
+
+  With -json the same is written as JSON, to attach to a bug report.
+`)
+	fs.StringVar(&text, "text", "", "Explain this text instead of files")
+	fs.StringVar(&modelPath, "model", "", "Load the model to tag with from this file")
+	fs.StringVar(&grammarPath, "grammar", "", "Find copyright notices by the grammar in this file")
+	fs.Float64Var(&threshold, "threshold", 0, "Only keep copyright notices with at least this confidence, from 0 to 1")
+	fs.BoolVar(&asJSON, "json", false, "Write JSON instead of a report")
+	fs.StringVar(&colorMode, "color", "auto", "Color the report: auto (when writing to a terminal), always or never")
+	fs.Parse(args)
+
+	if (text == "") == (fs.NArg() == 0) {
+		return fmt.Errorf("explain: give either -text or files")
+	}
+	var color bool
+	switch colorMode {
+	case "auto":
+		info, err := os.Stdout.Stat()
+		color = err == nil && info.Mode()&os.ModeCharDevice != 0
+	case "always":
+		color = true
+	case "never":
+	default:
+		return fmt.Errorf("explain: -color must be auto, always or never")
+	}
+
+	var model *tagger.Tagger
+	var err error
+	if modelPath != "" {
+		model, err = tagger.NewFromModel(modelPath)
+	} else {
+		model, err = tagger.NewDefault(tagger.DefaultKind)
+	}
+	if err != nil {
+		return err
+	}
+	if grammarPath != "" {
+		grammar, err := tagger.ReadGrammarFile(grammarPath)
+		if err != nil {
+			return err
+		}
+		model.SetGrammar(grammar)
+	}
+	model.Threshold = threshold
+
+	var explained []explainedText
+	if text != "" {
+		explained = append(explained, explainedText{Explanation: model.Explain([]byte(text))})
+	}
+	for _, path := range fs.Args() {
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		explained = append(explained, explainedText{Path: path, Explanation: model.Explain(raw)})
+	}
+
+	if asJSON {
+		out, err := json.MarshalIndent(explained, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(out, '\n'))
+		return err
+	}
+	for i, e := range explained {
+		if i > 0 {
+			fmt.Printf("\n")
+		}
+		if e.Path != "" {
+			fmt.Printf("%s:\n", e.Path)
+		}
+		err = e.WriteReport(os.Stdout, color)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			err := c.run(os.Args[2:])
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	if os.Args[1] == "-h" || os.Args[1] == "-help" || os.Args[1] == "help" {
+		usage()
+		return
+	}
+	log.Fatalf("unknown command %q, see '%s -h'", os.Args[1], os.Args[0])
+}